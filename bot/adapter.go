@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/chatbot"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/handler"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+)
+
+// ChatBotHandler 返回 Stream 模式的机器人回调，可传给 StreamClient.RegisterChatBotCallbackRouter
+// Stream 模式的回调响应不会发到会话中，回复通过消息中的 SessionWebhook 发送，设置 SetReplier 后由 Replier 发送
+// Stream SDK 的 chatbot.BotCallbackDataModel 不含 robotCode，消息的 RobotCode 取自 SetRobotCode；
+// 需要使用回调中原始的 robotCode 时改用 FrameHandler
+func (r *Router) ChatBotHandler() chatbot.IChatBotMessageHandler {
+	return func(ctx context.Context, data *chatbot.BotCallbackDataModel) ([]byte, error) {
+		msg, err := FromChatBotData(data)
+		if err != nil {
+			return nil, err
+		}
+		return r.handleStream(ctx, msg)
+	}
+}
+
+// FrameHandler 返回 Stream 模式机器人消息 Topic 的回调，直接解析原始数据，保留 robotCode 等 SDK 模型中没有的字段
+// 通过 StreamClient.RegisterCallbackRouter(payload.BotMessageCallbackTopic, router.FrameHandler()) 注册
+func (r *Router) FrameHandler() handler.IFrameHandler {
+	return func(ctx context.Context, df *payload.DataFrame) (*payload.DataFrameResponse, error) {
+		msg := &message.ReceiveMsg{}
+		if err := json.Unmarshal([]byte(df.Data), msg); err != nil {
+			return nil, err
+		}
+		data, err := r.handleStream(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		resp := payload.NewSuccessDataFrameResponse()
+		resp.SetData(string(data))
+		return resp, nil
+	}
+}
+
+// handleStream 分发 Stream 模式收到的消息并发送回复
+func (r *Router) handleStream(ctx context.Context, msg *message.ReceiveMsg) ([]byte, error) {
+	r.mutex.RLock()
+	replier := r.replier
+	if msg.RobotCode == "" {
+		msg.RobotCode = r.robotCode
+	}
+	r.mutex.RUnlock()
+
+	reply, err := r.Dispatch(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return []byte(`{}`), nil
+	}
+	if replier != nil {
+		_, err = replier.Reply(ctx, msg, reply)
+	} else {
+		_, err = msg.ReplyMessageWithContext(ctx, reply)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(`{}`), nil
+}
+
+// HTTPHandler 返回 HTTP 模式 outgoing 机器人的 http.Handler，回复作为同步响应返回
+// appSecret 用于校验请求签名，为空时拒绝所有请求，见 message.NewHandler
+func (r *Router) HTTPHandler(appSecret string) http.Handler {
+	return message.NewHandler(appSecret, r.Dispatch)
+}
+
+// FromChatBotData 将 Stream 回调数据转换为 message.ReceiveMsg，两者字段与钉钉回调 JSON 一致
+// SDK 模型中没有 robotCode，转换后 RobotCode 为空
+func FromChatBotData(data *chatbot.BotCallbackDataModel) (*message.ReceiveMsg, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &message.ReceiveMsg{}
+	if err = json.Unmarshal(raw, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
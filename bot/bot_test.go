@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/chatbot"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/payload"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+	"github.com/difyz9/dingtalk-sdk.git/session"
+)
+
+func textMsg(content string) *message.ReceiveMsg {
+	return &message.ReceiveMsg{
+		Msgtype:          message.TEXT,
+		Text:             message.Text{Content: content},
+		SenderStaffId:    "user1",
+		ConversationType: "2",
+		IsInAtList:       true,
+	}
+}
+
+func replyText(t *testing.T, reply message.Message) string {
+	t.Helper()
+	text, ok := reply.(*message.TextMessage)
+	if !ok {
+		t.Fatalf("Expected text reply, got %T", reply)
+	}
+	return text.Text.Content
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"a b  c", []string{"a", "b", "c"}},
+		{`"hello world" 'x y' z`, []string{"hello world", "x y", "z"}},
+		{`“北京 朝阳” 明天`, []string{"北京 朝阳", "明天"}},
+		{`a\ b ""`, []string{"a b", ""}},
+	}
+	for _, tt := range tests {
+		if got := ParseArgs(tt.input); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseArgs(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter("/")
+	router.Command("weather", "查询天气", func(c *Context) (message.Message, error) {
+		return message.NewTextMessage(c.Command + ":" + strings.Join(c.Args, "|")), nil
+	}).Alias("天气").WithUsage("<城市>")
+	router.Regex(regexp.MustCompile(`^(\d+)\s*\+\s*(\d+)$`), "加法", func(c *Context) (message.Message, error) {
+		return message.NewTextMessage(c.Matches[1] + "," + c.Matches[2]), nil
+	})
+	router.Command("secret", "", func(c *Context) (message.Message, error) {
+		return message.NewTextMessage("ok"), nil
+	}).MentionOnly().Hide()
+	router.EnableHelp()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		msg      *message.ReceiveMsg
+		expected string
+	}{
+		{"prefix", textMsg("/weather 北京 明天"), "weather:北京|明天"},
+		{"alias and mention", textMsg("@机器人 /天气 上海"), "weather:上海"},
+		{"case insensitive", textMsg("/WEATHER"), "weather:"},
+		{"regex", textMsg("1 + 2"), "1,2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := router.Dispatch(ctx, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := replyText(t, reply); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	// 命令名后必须是空白
+	if reply, _ := router.Dispatch(ctx, textMsg("/weatherx")); reply != nil {
+		t.Errorf("Expected no match, got %+v", reply)
+	}
+	notMentioned := textMsg("/secret")
+	notMentioned.IsInAtList = false
+	if reply, _ := router.Dispatch(ctx, notMentioned); reply != nil {
+		t.Errorf("Expected mention-only command to be skipped, got %+v", reply)
+	}
+
+	router.Fallback(func(c *Context) (message.Message, error) {
+		return message.NewTextMessage("unknown: " + c.Text), nil
+	})
+	reply, _ := router.Dispatch(ctx, textMsg("hi"))
+	if got := replyText(t, reply); got != "unknown: hi" {
+		t.Errorf("Expected fallback reply, got %q", got)
+	}
+
+	help := router.Help()
+	if !strings.Contains(help, "**/weather** <城市> - 查询天气（别名: /天气）") || strings.Contains(help, "secret") {
+		t.Errorf("Unexpected help text:\n%s", help)
+	}
+	reply, _ = router.Dispatch(ctx, textMsg("/帮助"))
+	if md, ok := reply.(*message.MarkDownMessage); !ok || md.MarkDown.Text != help {
+		t.Errorf("Expected help reply, got %+v", reply)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	router := NewRouter("")
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) (message.Message, error) {
+				order = append(order, name)
+				return next(c)
+			}
+		}
+	}
+	var logs bytes.Buffer
+	router.Use(Recovery(log.New(&logs, "", 0)), trace("router"))
+	router.Command("panic", "", func(c *Context) (message.Message, error) {
+		panic("boom")
+	})
+	router.Command("admin", "", func(c *Context) (message.Message, error) {
+		return message.NewTextMessage("ok"), nil
+	}).Use(trace("command"), AdminOnly())
+	router.Command("limited", "", func(c *Context) (message.Message, error) {
+		return message.NewTextMessage("ok"), nil
+	}).Use(RateLimit(2, time.Minute))
+	ctx := context.Background()
+
+	if _, err := router.Dispatch(ctx, textMsg("panic")); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected recovered panic error, got %v", err)
+	} else if strings.Contains(err.Error(), "goroutine") {
+		t.Errorf("Expected stack to be kept out of the error, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "goroutine") {
+		t.Errorf("Expected stack to be logged, got %q", logs.String())
+	}
+
+	order = nil
+	reply, _ := router.Dispatch(ctx, textMsg("admin"))
+	if got := replyText(t, reply); got != ForbiddenReply {
+		t.Errorf("Expected forbidden reply, got %q", got)
+	}
+	if strings.Join(order, ",") != "router,command" {
+		t.Errorf("Unexpected middleware order %v", order)
+	}
+	admin := textMsg("admin")
+	admin.IsAdmin = true
+	if reply, _ = router.Dispatch(ctx, admin); replyText(t, reply) != "ok" {
+		t.Error("Expected admin to pass")
+	}
+
+	for i, expected := range []string{"ok", "ok", RateLimitedReply} {
+		reply, _ = router.Dispatch(ctx, textMsg("limited"))
+		if got := replyText(t, reply); got != expected {
+			t.Errorf("Call %d: expected %q, got %q", i+1, expected, got)
+		}
+	}
+	other := textMsg("limited")
+	other.SenderStaffId = "user2"
+	if reply, _ = router.Dispatch(ctx, other); replyText(t, reply) != "ok" {
+		t.Error("Expected rate limit to be per sender")
+	}
+}
+
+func TestChatBotHandler(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer server.Close()
+
+	var robotCode string
+	router := NewRouter("")
+	router.Command("ping", "", func(c *Context) (message.Message, error) {
+		robotCode = c.Msg.RobotCode
+		return message.NewTextMessage("pong"), nil
+	})
+	router.Command("fail", "", func(c *Context) (message.Message, error) {
+		return nil, errors.New("failed")
+	})
+	handler := router.ChatBotHandler()
+
+	data := &chatbot.BotCallbackDataModel{
+		ConversationId: "cid1",
+		SenderStaffId:  "user1",
+		SessionWebhook: server.URL,
+		Msgtype:        "text",
+		Text:           chatbot.BotCallbackDataTextModel{Content: " ping"},
+	}
+	if _, err := handler(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := sent["text"].(map[string]interface{}); text["content"] != "pong" {
+		t.Errorf("Unexpected webhook body %v", sent)
+	}
+
+	if robotCode != "" {
+		t.Errorf("Expected empty RobotCode without SetRobotCode, got %q", robotCode)
+	}
+	router.SetRobotCode("robot123")
+	if _, err := handler(context.Background(), data); err != nil || robotCode != "robot123" {
+		t.Errorf("Expected RobotCode robot123, got %q (%v)", robotCode, err)
+	}
+
+	data.Text.Content = "fail"
+	if _, err := handler(context.Background(), data); err == nil {
+		t.Error("Expected handler error")
+	}
+
+	// FrameHandler 解析原始数据，保留回调中的 robotCode
+	router.SetRobotCode("")
+	raw := `{"conversationId":"cid1","senderStaffId":"user1","sessionWebhook":"` + server.URL + `","msgtype":"text","text":{"content":"ping"},"robotCode":"robot456"}`
+	resp, err := router.FrameHandler()(context.Background(), &payload.DataFrame{Data: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != payload.DataFrameResponseStatusCodeKOK || robotCode != "robot456" {
+		t.Errorf("Expected RobotCode robot456, got %q (code %d)", robotCode, resp.Code)
+	}
+}
+
+func TestExpectReply(t *testing.T) {
+	router := NewRouter("/")
+	router.UseSessions(session.NewManager(session.NewMemoryStore(), time.Minute))
+	router.Command("leave", "请假", func(c *Context) (message.Message, error) {
+		c.ExpectReply("leave_days")
+		return message.NewTextMessage("请假几天？"), nil
+	})
+	router.OnReply("leave_days", func(c *Context) (message.Message, error) {
+		if c.Text == "" {
+			c.ExpectReply("leave_days")
+			return message.NewTextMessage("请输入天数"), nil
+		}
+		c.Session.Set("days", c.Text)
+		return message.NewTextMessage("已申请 " + c.Text + " 天"), nil
+	})
+	router.Fallback(func(c *Context) (message.Message, error) {
+		return message.NewTextMessage("fallback"), nil
+	})
+	ctx := context.Background()
+
+	steps := []struct {
+		sender   string
+		text     string
+		expected string
+	}{
+		{"user1", "/leave", "请假几天？"},
+		{"user2", "3", "fallback"},
+		{"user1", "@机器人", "请输入天数"},
+		{"user1", "3", "已申请 3 天"},
+		{"user1", "3", "fallback"},
+	}
+	for i, step := range steps {
+		msg := textMsg(step.text)
+		msg.ConversationID = "cid1"
+		msg.SenderStaffId = step.sender
+		reply, err := router.Dispatch(ctx, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replyText(t, reply); got != step.expected {
+			t.Errorf("Step %d: expected %q, got %q", i+1, step.expected, got)
+		}
+	}
+}
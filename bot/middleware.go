@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+)
+
+// 中间件拒绝处理时的默认回复
+const (
+	ForbiddenReply   = "⛔ 无权限执行该命令"
+	RateLimitedReply = "⏳ 操作太频繁，请稍后再试"
+)
+
+// Logging 记录发送人、命令、耗时与错误，logger 为 nil 时使用 log.Default()
+func Logging(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (message.Message, error) {
+			start := time.Now()
+			reply, err := next(c)
+			logger.Printf("bot: sender=%s conversation=%s command=%q text=%q duration=%s err=%v",
+				c.Msg.GetSenderIdentifier(), c.Msg.ConversationID, c.Command, c.Text, time.Since(start), err)
+			return reply, err
+		}
+	}
+}
+
+// Recovery 将处理函数中的 panic 转换为错误，panic 的调用栈写入 logger，logger 为 nil 时使用 log.Default()
+// 返回的错误中不包含调用栈，避免经由 HTTP 响应等途径泄露
+func Recovery(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (reply message.Message, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logger.Printf("bot: panic: %v\n%s", p, debug.Stack())
+					reply = nil
+					err = fmt.Errorf("bot: panic: %v", p)
+				}
+			}()
+			return next(c)
+		}
+	}
+}
+
+// AuthFunc 判断发送人是否有权限
+type AuthFunc func(msg *message.ReceiveMsg) bool
+
+// Auth 权限校验，allow 返回 false 时回复 ForbiddenReply 且不执行处理函数
+func Auth(allow AuthFunc) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (message.Message, error) {
+			if !allow(c.Msg) {
+				return message.NewTextMessage(ForbiddenReply), nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// AdminOnly 仅允许企业管理员（IsAdmin）
+func AdminOnly() Middleware {
+	return Auth(func(msg *message.ReceiveMsg) bool {
+		return msg.IsAdmin
+	})
+}
+
+// AllowUsers 仅允许指定 senderStaffId 的用户
+func AllowUsers(staffIDs ...string) Middleware {
+	allowed := make(map[string]bool, len(staffIDs))
+	for _, id := range staffIDs {
+		allowed[id] = true
+	}
+	return Auth(func(msg *message.ReceiveMsg) bool {
+		return msg.SenderStaffId != "" && allowed[msg.SenderStaffId]
+	})
+}
+
+// RateLimit 按发送人限流，每个发送人在 per 时间窗口内最多处理 limit 条消息，超出时回复 RateLimitedReply
+func RateLimit(limit int, per time.Duration) Middleware {
+	limiter := &rateLimiter{limit: limit, per: per, windows: make(map[string]*rateWindow)}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (message.Message, error) {
+			if !limiter.allow(c.Msg.GetSenderIdentifier(), time.Now()) {
+				return message.NewTextMessage(RateLimitedReply), nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// rateLimiter 固定窗口计数限流
+type rateLimiter struct {
+	mutex     sync.Mutex
+	limit     int
+	per       time.Duration
+	windows   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 定期清理过期窗口，避免发送人过多时内存持续增长
+	if now.Sub(l.lastPrune) > l.per {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.per {
+				delete(l.windows, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.per {
+		l.windows[key] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
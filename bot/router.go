@@ -0,0 +1,325 @@
+// Package bot 提供机器人命令路由：前缀、正则与 @ 感知的命令匹配、参数解析、中间件与帮助文本，
+// 并可接入 Stream 模式的机器人回调与 HTTP 模式的 outgoing 回调
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+	"github.com/difyz9/dingtalk-sdk.git/session"
+)
+
+// Context 单条消息的处理上下文
+type Context struct {
+	context.Context
+	Msg     *message.ReceiveMsg
+	Text    string           // 去除开头 @ 提及后的消息文本
+	Command string           // 匹配到的命令名，正则命令与兜底处理时为空
+	Args    []string         // 命令参数，支持引号包裹含空格的参数
+	RawArgs string           // 命令名之后的原始文本
+	Matches []string         // 正则命令的匹配分组，Matches[0] 为完整匹配
+	Session *session.Session // 发送人在当前会话中的状态，未调用 Router.UseSessions 时为 nil；处理成功后自动保存
+}
+
+// Arg 返回第 i 个参数，不存在时返回空字符串
+func (c *Context) Arg(i int) string {
+	if i < 0 || i >= len(c.Args) {
+		return ""
+	}
+	return c.Args[i]
+}
+
+// ExpectReply 等待发送人的下一条回复，该回复将交给 Router.OnReply 注册的同名处理函数
+// 未调用 Router.UseSessions 时无效
+func (c *Context) ExpectReply(name string) {
+	if c.Session != nil {
+		c.Session.Expect(name)
+	}
+}
+
+// HandlerFunc 命令处理函数，返回的消息作为回复，返回 nil 表示不回复
+type HandlerFunc func(c *Context) (message.Message, error)
+
+// Middleware 中间件，包装处理函数
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Command 已注册的命令
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Usage       string // 参数说明，例如 "<城市> [日期]"
+	Hidden      bool   // 不在帮助文本中显示
+
+	pattern     *regexp.Regexp
+	mentionOnly bool
+	handler     HandlerFunc
+	middlewares []Middleware
+}
+
+// Alias 添加别名
+func (cmd *Command) Alias(names ...string) *Command {
+	cmd.Aliases = append(cmd.Aliases, names...)
+	return cmd
+}
+
+// WithUsage 设置参数说明
+func (cmd *Command) WithUsage(usage string) *Command {
+	cmd.Usage = usage
+	return cmd
+}
+
+// MentionOnly 群聊中仅在机器人被 @ 时匹配，单聊不受影响
+func (cmd *Command) MentionOnly() *Command {
+	cmd.mentionOnly = true
+	return cmd
+}
+
+// Hide 不在帮助文本中显示
+func (cmd *Command) Hide() *Command {
+	cmd.Hidden = true
+	return cmd
+}
+
+// Use 添加仅作用于该命令的中间件，在路由级中间件之后执行
+func (cmd *Command) Use(middlewares ...Middleware) *Command {
+	cmd.middlewares = append(cmd.middlewares, middlewares...)
+	return cmd
+}
+
+// Router 命令路由
+type Router struct {
+	prefix      string
+	mutex       sync.RWMutex
+	commands    []*Command
+	middlewares []Middleware
+	fallback    HandlerFunc
+	sessions    *session.Manager
+	replies     map[string]HandlerFunc
+	replier     *message.Replier
+	robotCode   string
+}
+
+// NewRouter 创建命令路由，prefix 为命令前缀（例如 "/"），为空时直接匹配命令名
+func NewRouter(prefix string) *Router {
+	return &Router{prefix: prefix}
+}
+
+// Use 添加路由级中间件，按添加顺序由外到内执行，作用于全部命令与兜底处理函数
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Command 注册前缀命令，消息文本为 prefix+name 或以 prefix+name 加空白开头时匹配，命令名不区分大小写
+func (r *Router) Command(name, description string, handler HandlerFunc) *Command {
+	cmd := &Command{Name: name, Description: description, handler: handler}
+	r.add(cmd)
+	return cmd
+}
+
+// Regex 注册正则命令，pattern 与去除 @ 提及后的完整文本匹配
+func (r *Router) Regex(pattern *regexp.Regexp, description string, handler HandlerFunc) *Command {
+	cmd := &Command{Name: pattern.String(), Description: description, pattern: pattern, handler: handler}
+	r.add(cmd)
+	return cmd
+}
+
+// Fallback 设置未匹配到命令时的处理函数
+func (r *Router) Fallback(handler HandlerFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fallback = handler
+}
+
+// EnableHelp 注册帮助命令，回复 Help 生成的帮助文本，names 为空时使用 "help" 与 "帮助"
+func (r *Router) EnableHelp(names ...string) *Command {
+	if len(names) == 0 {
+		names = []string{"help", "帮助"}
+	}
+	return r.Command(names[0], "查看帮助", func(c *Context) (message.Message, error) {
+		return message.NewMarkdownMessage("帮助", r.Help()), nil
+	}).Alias(names[1:]...)
+}
+
+// SetReplier 设置 ChatBotHandler 发送回复使用的 Replier，SessionWebhook 过期时可降级为企业机器人接口发送
+func (r *Router) SetReplier(replier *message.Replier) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.replier = replier
+}
+
+// SetRobotCode 设置机器人的 robotCode，ChatBotHandler 收到的消息中没有 robotCode，
+// Replier 降级发送与卡片投放依赖该字段
+func (r *Router) SetRobotCode(robotCode string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.robotCode = robotCode
+}
+
+// UseSessions 启用会话状态，处理函数可通过 Context.Session 读写状态
+func (r *Router) UseSessions(manager *session.Manager) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sessions = manager
+}
+
+// OnReply 注册等待回复的处理函数：发送人处于 Context.ExpectReply(name) 标记的等待状态时，
+// 下一条消息优先交给 handler 处理而不进行命令匹配。调用前等待状态已清除，handler 可再次 ExpectReply 实现多步对话
+func (r *Router) OnReply(name string, handler HandlerFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.replies == nil {
+		r.replies = make(map[string]HandlerFunc)
+	}
+	r.replies[name] = handler
+}
+
+func (r *Router) add(cmd *Command) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = append(r.commands, cmd)
+}
+
+// Help 生成 Markdown 格式的命令列表
+func (r *Router) Help() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("### 可用命令\n\n")
+	for _, cmd := range r.commands {
+		if cmd.Hidden {
+			continue
+		}
+		if cmd.pattern != nil {
+			fmt.Fprintf(&b, "- `%s`", cmd.pattern.String())
+		} else {
+			fmt.Fprintf(&b, "- **%s%s**", r.prefix, cmd.Name)
+			if cmd.Usage != "" {
+				fmt.Fprintf(&b, " %s", cmd.Usage)
+			}
+		}
+		if cmd.Description != "" {
+			fmt.Fprintf(&b, " - %s", cmd.Description)
+		}
+		if len(cmd.Aliases) > 0 {
+			aliases := make([]string, len(cmd.Aliases))
+			for i, alias := range cmd.Aliases {
+				aliases[i] = r.prefix + alias
+			}
+			fmt.Fprintf(&b, "（别名: %s）", strings.Join(aliases, "、"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Dispatch 匹配命令并执行，未匹配且未设置兜底处理函数时返回 nil
+func (r *Router) Dispatch(ctx context.Context, msg *message.ReceiveMsg) (message.Message, error) {
+	c := &Context{Context: ctx, Msg: msg, Text: stripMentions(msg.PlainText())}
+
+	r.mutex.RLock()
+	sessions := r.sessions
+	replies := r.replies
+	middlewares := r.middlewares
+	r.mutex.RUnlock()
+
+	var (
+		handler HandlerFunc
+		cmd     *Command
+	)
+	if sessions != nil {
+		// 同一发送人的消息串行处理，避免并发的读取、修改与保存互相覆盖
+		defer sessions.Lock(msg)()
+		var err error
+		if c.Session, err = sessions.Load(ctx, msg); err != nil {
+			return nil, err
+		}
+		if expecting := c.Session.Expecting; expecting != "" && replies[expecting] != nil {
+			c.Session.Expecting = ""
+			handler = replies[expecting]
+		}
+	}
+	if handler == nil {
+		r.mutex.RLock()
+		handler, cmd = r.match(c)
+		r.mutex.RUnlock()
+	}
+
+	if handler == nil {
+		return nil, nil
+	}
+	if cmd != nil {
+		for i := len(cmd.middlewares) - 1; i >= 0; i-- {
+			handler = cmd.middlewares[i](handler)
+		}
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	reply, err := handler(c)
+	if err == nil && c.Session != nil {
+		err = sessions.Save(ctx, msg, c.Session)
+	}
+	return reply, err
+}
+
+// match 按注册顺序匹配命令并填充上下文
+func (r *Router) match(c *Context) (HandlerFunc, *Command) {
+	mentioned := c.Msg.IsInAtList || c.Msg.ConversationType == message.ConversationTypeSingle
+	for _, cmd := range r.commands {
+		if cmd.mentionOnly && !mentioned {
+			continue
+		}
+		if cmd.pattern != nil {
+			if matches := cmd.pattern.FindStringSubmatch(c.Text); matches != nil {
+				c.Matches = matches
+				return cmd.handler, cmd
+			}
+			continue
+		}
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if rest, ok := cutCommand(c.Text, r.prefix+name); ok {
+				c.Command = cmd.Name
+				c.RawArgs = rest
+				c.Args = ParseArgs(rest)
+				return cmd.handler, cmd
+			}
+		}
+	}
+	return r.fallback, nil
+}
+
+// cutCommand 判断 text 是否以命令开头，命令后必须是文本结尾或空白
+func cutCommand(text, command string) (string, bool) {
+	if len(text) < len(command) || !strings.EqualFold(text[:len(command)], command) {
+		return "", false
+	}
+	rest := text[len(command):]
+	if r, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(r) {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// stripMentions 去除文本开头的 @ 提及，群聊中 @机器人 时内容可能以 "@机器人名" 开头，其后通常是 U+2005 空格
+func stripMentions(text string) string {
+	text = strings.TrimSpace(text)
+	for strings.HasPrefix(text, "@") {
+		i := strings.IndexFunc(text, unicode.IsSpace)
+		if i < 0 {
+			return ""
+		}
+		text = strings.TrimSpace(text[i:])
+	}
+	return text
+}
@@ -0,0 +1,145 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	testToken    = "123456"
+	testAESKey   = "4g5j64qlyl3zvetqxz5jiocdr586fn2zvjpa8zls3ij"
+	testOwnerKey = "suite4xxxxxxxxxxxxxxx"
+)
+
+func TestCrypto(t *testing.T) {
+	crypto, err := NewCrypto(testToken, testAESKey, testOwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypt, err := crypto.Encrypt([]byte("success"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := crypto.Decrypt(encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "success" {
+		t.Errorf("Expected 'success', got %q", plaintext)
+	}
+
+	other, _ := NewCrypto(testToken, testAESKey, "other")
+	if _, err = other.Decrypt(encrypt); !errors.Is(err, ErrInvalidOwnerKey) {
+		t.Errorf("Expected ErrInvalidOwnerKey, got %v", err)
+	}
+
+	signature := crypto.Signature("1445827045067", "nEXhMP4r", encrypt)
+	if err = crypto.VerifySignature(signature, "1445827045067", "nEXhMP4r", encrypt); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err = crypto.VerifySignature(signature, "1445827045068", "nEXhMP4r", encrypt); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	if _, err = NewCrypto(testToken, "short", testOwnerKey); !errors.Is(err, ErrInvalidAESKey) {
+		t.Errorf("Expected ErrInvalidAESKey, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler, err := NewHandler(testToken, testAESKey, testOwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received *UserEvent
+	handler.HandleUserEvent(func(ctx context.Context, event *UserEvent) error {
+		received = event
+		return nil
+	})
+	handler.HandleDeptEvent(func(ctx context.Context, event *DeptEvent) error {
+		return errors.New("db unavailable")
+	})
+
+	post := func(event string, tamper bool) *httptest.ResponseRecorder {
+		resp, err := handler.crypto.EncryptResponse([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tamper {
+			resp.MsgSignature = "invalid"
+		}
+		query := url.Values{}
+		query.Set("msg_signature", resp.MsgSignature)
+		query.Set("timestamp", resp.TimeStamp)
+		query.Set("nonce", resp.Nonce)
+		body, _ := json.Marshal(map[string]string{"encrypt": resp.Encrypt})
+
+		req := httptest.NewRequest("POST", "/callback?"+query.Encode(), bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("check url", func(t *testing.T) {
+		rec := post(`{"EventType":"check_url"}`, false)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		resp := &EncryptedResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+			t.Fatal(err)
+		}
+		if err := handler.crypto.VerifySignature(resp.MsgSignature, resp.TimeStamp, resp.Nonce, resp.Encrypt); err != nil {
+			t.Errorf("Expected valid response signature, got %v", err)
+		}
+		plaintext, err := handler.crypto.Decrypt(resp.Encrypt)
+		if err != nil || string(plaintext) != "success" {
+			t.Errorf("Expected encrypted 'success', got %q, %v", plaintext, err)
+		}
+	})
+
+	t.Run("user event", func(t *testing.T) {
+		rec := post(`{"EventType":"user_add_org","CorpId":"ding123","UserId":["user1","user2"]}`, false)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		if received == nil || received.CorpID != "ding123" || len(received.UserIDs) != 2 {
+			t.Errorf("Unexpected event: %+v", received)
+		}
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		var logs bytes.Buffer
+		handler.ErrorLog = log.New(&logs, "", 0)
+		rec := post(`{"EventType":"org_dept_create","DeptId":[1]}`, false)
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "db unavailable") || !strings.Contains(logs.String(), "db unavailable") {
+			t.Errorf("Expected error to be logged but not returned, body %q, log %q", rec.Body.String(), logs.String())
+		}
+	})
+
+	t.Run("unhandled event", func(t *testing.T) {
+		rec := post(`{"EventType":"label_user_change"}`, false)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		rec := post(`{"EventType":"check_url"}`, true)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Package callback 实现钉钉 HTTP 事件订阅的回调接收，包括签名校验、AES 加解密与事件分发
+// 文档: https://open.dingtalk.com/document/orgapp/configure-event-subcription
+package callback
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidSignature 回调签名不匹配
+	ErrInvalidSignature = errors.New("callback: invalid signature")
+	// ErrInvalidAESKey aes_key 格式错误，应为 43 位字符
+	ErrInvalidAESKey = errors.New("callback: invalid aes key")
+	// ErrInvalidOwnerKey 解密后的 corpId/appKey 与配置不一致
+	ErrInvalidOwnerKey = errors.New("callback: owner key mismatch")
+	// ErrInvalidCiphertext 密文格式错误
+	ErrInvalidCiphertext = errors.New("callback: invalid ciphertext")
+)
+
+// blockSize 钉钉加密使用 32 字节的 PKCS#7 填充
+const blockSize = 32
+
+// Crypto 钉钉回调加解密
+// 明文结构为 16 字节随机串 + 4 字节网络序消息长度 + 消息 + ownerKey，AES-256-CBC 加密后 Base64 编码
+type Crypto struct {
+	token    string
+	key      []byte
+	ownerKey string
+}
+
+// NewCrypto 创建回调加解密器
+// token 与 aesKey 为开发者后台事件订阅中配置的签名 token 与加密 aes_key；
+// ownerKey 企业内部应用填写应用的 AppKey（旧版回调填写 CorpId），第三方应用填写 SuiteKey
+func NewCrypto(token, aesKey, ownerKey string) (*Crypto, error) {
+	if len(aesKey) != 43 {
+		return nil, ErrInvalidAESKey
+	}
+	key, err := base64.StdEncoding.DecodeString(aesKey + "=")
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalidAESKey
+	}
+	return &Crypto{token: token, key: key, ownerKey: ownerKey}, nil
+}
+
+// Signature 计算签名：token、timestamp、nonce、encrypt 字典序排序拼接后取 SHA1
+func (c *Crypto) Signature(timestamp, nonce, encrypt string) string {
+	parts := []string{c.token, timestamp, nonce, encrypt}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifySignature 校验签名
+func (c *Crypto) VerifySignature(signature, timestamp, nonce, encrypt string) error {
+	expected := c.Signature(timestamp, nonce, encrypt)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Encrypt 加密明文
+func (c *Crypto) Encrypt(plaintext []byte) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(plaintext)))
+
+	buf := bytes.NewBuffer(make([]byte, 0, 20+len(plaintext)+len(c.ownerKey)+blockSize))
+	buf.Write(random)
+	buf.Write(length)
+	buf.Write(plaintext)
+	buf.WriteString(c.ownerKey)
+	data := pkcs7Pad(buf.Bytes())
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, c.key[:aes.BlockSize]).CryptBlocks(ciphertext, data)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt 解密密文并校验 ownerKey
+func (c *Crypto) Decrypt(encrypt string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, c.key[:aes.BlockSize]).CryptBlocks(data, ciphertext)
+
+	data, err = pkcs7Unpad(data)
+	if err != nil || len(data) < 20 {
+		return nil, ErrInvalidCiphertext
+	}
+	length := int(binary.BigEndian.Uint32(data[16:20]))
+	if length > len(data)-20 {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext := data[20 : 20+length]
+	if c.ownerKey != "" && string(data[20+length:]) != c.ownerKey {
+		return nil, ErrInvalidOwnerKey
+	}
+	return plaintext, nil
+}
+
+func pkcs7Pad(data []byte) []byte {
+	padding := blockSize - len(data)%blockSize
+	return append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	padding := int(data[len(data)-1])
+	if padding < 1 || padding > blockSize || padding > len(data) {
+		return nil, ErrInvalidCiphertext
+	}
+	return data[:len(data)-padding], nil
+}
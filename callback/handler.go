@@ -0,0 +1,259 @@
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/internal/httperr"
+)
+
+// 常用事件类型
+// 文档: https://open.dingtalk.com/document/orgapp/address-book-events
+const (
+	EventCheckURL           = "check_url"
+	EventUserAddOrg         = "user_add_org"
+	EventUserModifyOrg      = "user_modify_org"
+	EventUserLeaveOrg       = "user_leave_org"
+	EventOrgDeptCreate      = "org_dept_create"
+	EventOrgDeptModify      = "org_dept_modify"
+	EventOrgDeptRemove      = "org_dept_remove"
+	EventBpmsTaskChange     = "bpms_task_change"
+	EventBpmsInstanceChange = "bpms_instance_change"
+)
+
+// maxBodySize 回调请求体大小上限
+const maxBodySize = 1 << 20
+
+// Event 解密后的回调事件
+type Event struct {
+	EventType string          `json:"EventType"`
+	CorpID    string          `json:"CorpId"`
+	Raw       json.RawMessage `json:"-"` // 解密后的完整事件 JSON
+}
+
+// Decode 将事件解析为具体的事件结构
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// UserEvent 通讯录用户变更事件
+type UserEvent struct {
+	EventType  string   `json:"EventType"`
+	CorpID     string   `json:"CorpId"`
+	UserIDs    []string `json:"UserId"`
+	OptStaffID string   `json:"OptStaffId"`
+}
+
+// DeptEvent 通讯录部门变更事件
+type DeptEvent struct {
+	EventType string  `json:"EventType"`
+	CorpID    string  `json:"CorpId"`
+	DeptIDs   []int64 `json:"DeptId"`
+}
+
+// BpmsEvent 审批实例或任务变更事件
+type BpmsEvent struct {
+	EventType         string `json:"EventType"`
+	CorpID            string `json:"corpId"`
+	ProcessInstanceID string `json:"processInstanceId"`
+	ProcessCode       string `json:"processCode"`
+	BusinessID        string `json:"businessId"`
+	Title             string `json:"title"`
+	Type              string `json:"type"`   // start、finish、terminate 等
+	Result            string `json:"result"` // agree、refuse，仅结束时有值
+	StaffID           string `json:"staffId"`
+	CreateTime        int64  `json:"createTime"`
+	FinishTime        int64  `json:"finishTime"`
+}
+
+// EventHandler 事件处理函数，返回错误时响应 500，钉钉会稍后重推
+type EventHandler func(ctx context.Context, event *Event) error
+
+// Handler HTTP 事件订阅回调处理器，实现 http.Handler
+type Handler struct {
+	crypto   *Crypto
+	mutex    sync.RWMutex
+	handlers map[string]EventHandler
+	fallback EventHandler
+
+	// ErrorLog 记录事件处理失败的原因，为 nil 时使用 log.Default()
+	ErrorLog *log.Logger
+}
+
+// NewHandler 创建回调处理器，参数含义见 NewCrypto
+func NewHandler(token, aesKey, ownerKey string) (*Handler, error) {
+	crypto, err := NewCrypto(token, aesKey, ownerKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		crypto:   crypto,
+		handlers: make(map[string]EventHandler),
+	}, nil
+}
+
+// Handle 注册指定事件类型的处理函数
+func (h *Handler) Handle(eventType string, handler EventHandler) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.handlers[eventType] = handler
+}
+
+// HandleDefault 注册未匹配到事件类型时的处理函数，未注册时直接返回成功
+func (h *Handler) HandleDefault(handler EventHandler) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.fallback = handler
+}
+
+// HandleUserEvent 注册用户加入、变更、离职事件的处理函数
+func (h *Handler) HandleUserEvent(handler func(ctx context.Context, event *UserEvent) error) {
+	typed := func(ctx context.Context, event *Event) error {
+		userEvent := &UserEvent{}
+		if err := event.Decode(userEvent); err != nil {
+			return err
+		}
+		return handler(ctx, userEvent)
+	}
+	for _, eventType := range []string{EventUserAddOrg, EventUserModifyOrg, EventUserLeaveOrg} {
+		h.Handle(eventType, typed)
+	}
+}
+
+// HandleDeptEvent 注册部门创建、变更、删除事件的处理函数
+func (h *Handler) HandleDeptEvent(handler func(ctx context.Context, event *DeptEvent) error) {
+	typed := func(ctx context.Context, event *Event) error {
+		deptEvent := &DeptEvent{}
+		if err := event.Decode(deptEvent); err != nil {
+			return err
+		}
+		return handler(ctx, deptEvent)
+	}
+	for _, eventType := range []string{EventOrgDeptCreate, EventOrgDeptModify, EventOrgDeptRemove} {
+		h.Handle(eventType, typed)
+	}
+}
+
+// HandleBpmsEvent 注册审批实例与审批任务变更事件的处理函数
+func (h *Handler) HandleBpmsEvent(handler func(ctx context.Context, event *BpmsEvent) error) {
+	typed := func(ctx context.Context, event *Event) error {
+		bpmsEvent := &BpmsEvent{}
+		if err := event.Decode(bpmsEvent); err != nil {
+			return err
+		}
+		return handler(ctx, bpmsEvent)
+	}
+	for _, eventType := range []string{EventBpmsInstanceChange, EventBpmsTaskChange} {
+		h.Handle(eventType, typed)
+	}
+}
+
+// ServeHTTP 校验签名、解密事件并分发，处理成功后返回加密的 "success"
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	signature := query.Get("msg_signature")
+	if signature == "" {
+		signature = query.Get("signature")
+	}
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+
+	body := struct {
+		Encrypt string `json:"encrypt"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if err := h.crypto.VerifySignature(signature, timestamp, nonce, body.Encrypt); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	plaintext, err := h.crypto.Decrypt(body.Encrypt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event := &Event{Raw: plaintext}
+	if err = json.Unmarshal(plaintext, event); err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+
+	if event.EventType != EventCheckURL {
+		if err = h.dispatch(r.Context(), event); err != nil {
+			httperr.Internal(w, h.ErrorLog, "callback", err)
+			return
+		}
+	}
+
+	resp, err := h.SuccessResponse()
+	if err != nil {
+		httperr.Internal(w, h.ErrorLog, "callback", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// dispatch 按事件类型分发
+func (h *Handler) dispatch(ctx context.Context, event *Event) error {
+	h.mutex.RLock()
+	handler, ok := h.handlers[event.EventType]
+	if !ok {
+		handler = h.fallback
+	}
+	h.mutex.RUnlock()
+
+	if handler == nil {
+		return nil
+	}
+	return handler(ctx, event)
+}
+
+// EncryptedResponse 加密的回调响应
+type EncryptedResponse struct {
+	MsgSignature string `json:"msg_signature"`
+	TimeStamp    string `json:"timeStamp"`
+	Nonce        string `json:"nonce"`
+	Encrypt      string `json:"encrypt"`
+}
+
+// SuccessResponse 生成加密的 "success" 响应，钉钉据此判断回调处理成功
+func (h *Handler) SuccessResponse() (*EncryptedResponse, error) {
+	return h.crypto.EncryptResponse([]byte("success"))
+}
+
+// EncryptResponse 加密响应内容并签名
+func (c *Crypto) EncryptResponse(plaintext []byte) (*EncryptedResponse, error) {
+	encrypt, err := c.Encrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonceBytes := make([]byte, 8)
+	if _, err = rand.Read(nonceBytes); err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	nonce := hex.EncodeToString(nonceBytes)
+	return &EncryptedResponse{
+		MsgSignature: c.Signature(timestamp, nonce, encrypt),
+		TimeStamp:    timestamp,
+		Nonce:        nonce,
+		Encrypt:      encrypt,
+	}, nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -56,8 +57,11 @@ type Credential struct {
 // DingTalkClientInterface 钉钉客户端接口
 type DingTalkClientInterface interface {
 	GetAccessToken() (string, error)
+	GetAccessTokenWithContext(ctx context.Context) (string, error)
 	UploadMedia(content []byte, filename, mediaType, mimeType string) (*MediaUploadResult, error)
+	UploadMediaWithContext(ctx context.Context, content []byte, filename, mediaType, mimeType string) (*MediaUploadResult, error)
 	GetOpenConversationId(chatID string) (string, error)
+	GetOpenConversationIdWithContext(ctx context.Context, chatID string) (string, error)
 }
 
 // DingTalkClient 钉钉客户端
@@ -66,6 +70,17 @@ type DingTalkClient struct {
 	AccessToken string
 	expireAt    int64
 	mutex       sync.Mutex
+
+	httpClient      *http.Client
+	oapiBaseURL     string
+	openAPIBaseURL  string
+	timeout         time.Duration
+	userAgent       string
+	retryPolicy     *RetryPolicy
+	refreshAhead    time.Duration
+	refreshing      *tokenRefresh
+	invalidExpireAt int64
+	tokenStore      TokenStore
 }
 
 // DingTalkClientManagerInterface 钉钉客户端管理器接口
@@ -81,19 +96,23 @@ type DingTalkClientManager struct {
 }
 
 // NewDingTalkClient 创建钉钉客户端
-func NewDingTalkClient(credential Credential) *DingTalkClient {
-	return &DingTalkClient{
+func NewDingTalkClient(credential Credential, opts ...Option) *DingTalkClient {
+	c := &DingTalkClient{
 		Credential: credential,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// NewDingTalkClientManager 创建钉钉客户端管理器
-func NewDingTalkClientManager(credentials []Credential) *DingTalkClientManager {
+// NewDingTalkClientManager 创建钉钉客户端管理器，opts 应用于所有客户端
+func NewDingTalkClientManager(credentials []Credential, opts ...Option) *DingTalkClientManager {
 	clients := make(map[string]*DingTalkClient)
 
 	if credentials != nil {
 		for _, credential := range credentials {
-			clients[credential.ClientID] = NewDingTalkClient(credential)
+			clients[credential.ClientID] = NewDingTalkClient(credential, opts...)
 		}
 	}
 	return &DingTalkClientManager{
@@ -114,105 +133,176 @@ func (m *DingTalkClientManager) GetClientByOAuthClientID(clientId string) DingTa
 
 // GetAccessToken 获取 AccessToken（自动缓存）
 func (c *DingTalkClient) GetAccessToken() (string, error) {
-	accessToken := ""
-	{
-		// 先查询缓存
-		c.mutex.Lock()
-		now := time.Now().Unix()
-		if c.expireAt > 0 && c.AccessToken != "" && (now+60) < c.expireAt {
-			// 预留一分钟有效期避免在Token过期的临界点调用接口出现401错误
-			accessToken = c.AccessToken
+	return c.GetAccessTokenWithContext(context.Background())
+}
+
+// GetAccessTokenWithContext 获取 AccessToken（自动缓存），ctx 用于控制等待刷新的超时与取消
+// 并发调用只会触发一次刷新请求；Token 临近过期时返回缓存并在后台提前刷新
+func (c *DingTalkClient) GetAccessTokenWithContext(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	now := time.Now().Unix()
+	if c.expireAt > 0 && c.AccessToken != "" && (now+60) < c.expireAt {
+		// 预留一分钟有效期避免在Token过期的临界点调用接口出现401错误
+		accessToken := c.AccessToken
+		if now+c.refreshAheadSeconds() >= c.expireAt {
+			// 进入提前刷新窗口，后台刷新，当前调用不阻塞
+			c.startRefreshLocked(ctx)
 		}
 		c.mutex.Unlock()
-	}
-	if accessToken != "" {
 		return accessToken, nil
 	}
+	refresh := c.startRefreshLocked(ctx)
+	c.mutex.Unlock()
 
-	tokenResult, err := c.getAccessTokenFromDingTalk()
-	if err != nil {
-		return "", err
+	select {
+	case <-refresh.done:
+		return refresh.accessToken, refresh.err
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
+}
 
-	{
-		// 更新缓存
-		c.mutex.Lock()
-		c.AccessToken = tokenResult.AccessToken
-		c.expireAt = time.Now().Unix() + int64(tokenResult.ExpiresIn)
-		c.mutex.Unlock()
-	}
-	return tokenResult.AccessToken, nil
+// tokenRefresh 一次进行中的 Token 刷新，所有等待者共享结果
+type tokenRefresh struct {
+	done        chan struct{}
+	accessToken string
+	err         error
 }
 
-// UploadMedia 上传媒体文件
-func (c *DingTalkClient) UploadMedia(content []byte, filename, mediaType, mimeType string) (*MediaUploadResult, error) {
-	// OpenAPI doc: https://open.dingtalk.com/document/isvapp/upload-media-files
-	accessToken, err := c.GetAccessToken()
-	if err != nil {
-		return nil, err
-	}
-	if len(accessToken) == 0 {
-		return nil, errors.New("empty access token")
-	}
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("media", filename)
-	if err != nil {
-		return nil, err
-	}
-	_, err = part.Write(content)
-	if err != nil {
-		return nil, err
-	}
-	if err = writer.WriteField("type", mediaType); err != nil {
-		return nil, err
-	}
-	err = writer.Close()
-	if err != nil {
-		return nil, err
+// startRefreshLocked 启动 Token 刷新，已有刷新在进行时直接复用，调用方需持有 mutex
+func (c *DingTalkClient) startRefreshLocked(ctx context.Context) *tokenRefresh {
+	if c.refreshing != nil {
+		return c.refreshing
+	}
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	c.refreshing = refresh
+	staleExpireAt := c.expireAt
+	if c.AccessToken == "" {
+		staleExpireAt = c.invalidExpireAt
+	}
+	// 刷新结果被多个调用方共享，不随发起方的 ctx 取消，但需限制总耗时，避免存储无响应时刷新永远不结束
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRefreshTimeout)
+	go func() {
+		defer cancel()
+		c.refreshAccessToken(ctx, staleExpireAt, refresh)
+	}()
+	return refresh
+}
+
+// refreshAccessToken 获取新的 Token 并更新缓存
+func (c *DingTalkClient) refreshAccessToken(ctx context.Context, staleExpireAt int64, refresh *tokenRefresh) {
+	accessToken, expireAt, err := c.fetchAccessToken(ctx, staleExpireAt)
+
+	c.mutex.Lock()
+	if err == nil {
+		c.AccessToken = accessToken
+		c.expireAt = expireAt.Unix()
+		c.invalidExpireAt = 0
+		refresh.accessToken = accessToken
+	}
+	refresh.err = err
+	c.refreshing = nil
+	c.mutex.Unlock()
+	close(refresh.done)
+}
+
+// refreshAheadSeconds Token 过期前多少秒开始后台刷新
+func (c *DingTalkClient) refreshAheadSeconds() int64 {
+	if c.refreshAhead > 0 {
+		return int64(c.refreshAhead / time.Second)
 	}
+	return int64(defaultRefreshAhead / time.Second)
+}
 
-	// Create a new HTTP request to upload the media file
-	url := fmt.Sprintf("https://oapi.dingtalk.com/media/upload?access_token=%s", url2.QueryEscape(accessToken))
-	req, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return nil, err
+// InvalidateAccessToken 使缓存的 AccessToken 失效，下次调用时重新获取
+func (c *DingTalkClient) InvalidateAccessToken() {
+	c.mutex.Lock()
+	if c.AccessToken != "" {
+		c.invalidExpireAt = c.expireAt
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.AccessToken = ""
+	c.expireAt = 0
+	c.mutex.Unlock()
+}
 
-	// Send the HTTP request and parse the response
-	client := &http.Client{
-		Timeout: time.Second * 60,
+// InvalidateAccessTokenIfCurrent 仅当缓存的仍是 accessToken 时使其失效，用于请求返回 Token 失效后，
+// 避免丢弃其他协程在请求期间刚刷新的 Token
+func (c *DingTalkClient) InvalidateAccessTokenIfCurrent(accessToken string) {
+	c.mutex.Lock()
+	if c.AccessToken == accessToken {
+		c.invalidExpireAt = c.expireAt
+		c.AccessToken = ""
+		c.expireAt = 0
 	}
-	res, err := client.Do(req)
+	c.mutex.Unlock()
+}
+
+// UploadMedia 上传媒体文件
+func (c *DingTalkClient) UploadMedia(content []byte, filename, mediaType, mimeType string) (*MediaUploadResult, error) {
+	return c.UploadMediaWithContext(context.Background(), content, filename, mediaType, mimeType)
+}
+
+// UploadMediaWithContext 上传媒体文件，ctx 取消时中断上传
+func (c *DingTalkClient) UploadMediaWithContext(ctx context.Context, content []byte, filename, mediaType, mimeType string) (*MediaUploadResult, error) {
+	// OpenAPI doc: https://open.dingtalk.com/document/isvapp/upload-media-files
+	bodyBytes, err := c.call(ctx, true, defaultLongTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("media", filename)
+		if err != nil {
+			return nil, err
+		}
+		_, err = part.Write(content)
+		if err != nil {
+			return nil, err
+		}
+		if err = writer.WriteField("type", mediaType); err != nil {
+			return nil, err
+		}
+		err = writer.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// Create a new HTTP request to upload the media file
+		url := c.oapiURL(fmt.Sprintf("/media/upload?access_token=%s", url2.QueryEscape(accessToken)))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
 	// Parse the response body as JSON and extract the media ID
 	media := &MediaUploadResult{}
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
 	if err = json.Unmarshal(bodyBytes, media); err != nil {
 		return nil, err
 	}
-	if media.ErrorCode != 0 {
-		return nil, errors.New(media.ErrorMessage)
-	}
 	return media, nil
 }
 
 // SendRobotMessage 发送企业内部机器人消息
 // 文档: https://open.dingtalk.com/document/orgapp/robot-sends-group-messages
 func (c *DingTalkClient) SendRobotMessage(chatID string, message interface{}) error {
-	accessToken, err := c.GetAccessToken()
-	if err != nil {
+	return c.SendRobotMessageWithContext(context.Background(), chatID, message)
+}
+
+// SendRobotMessageWithContext 发送企业内部机器人消息，ctx 取消时中断请求
+// message 可以是 message 包中的类型化消息（发送前会调用 Validate 校验），也可以是任意可序列化为 JSON 的结构
+func (c *DingTalkClient) SendRobotMessageWithContext(ctx context.Context, chatID string, message interface{}) error {
+	if err := validateMessage(message); err != nil {
 		return err
 	}
-
+	if converter, ok := message.(ChatMessageConverter); ok {
+		var err error
+		if message, err = converter.ToChatMessage(); err != nil {
+			return err
+		}
+	}
 	// 构造请求参数
 	params := map[string]interface{}{
 		"chatId": chatID,
@@ -224,61 +314,30 @@ func (c *DingTalkClient) SendRobotMessage(chatID string, message interface{}) er
 		return err
 	}
 
-	url := fmt.Sprintf("https://oapi.dingtalk.com/chat/send?access_token=%s", url2.QueryEscape(accessToken))
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	var result map[string]interface{}
-	bodyBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
-	if err = json.Unmarshal(bodyBytes, &result); err != nil {
-		return err
-	}
-
-	if errcode, ok := result["errcode"].(float64); ok && errcode != 0 {
-		return fmt.Errorf("send robot message failed: %v", result["errmsg"])
-	}
-	return nil
+	_, err = c.callSend(ctx, true, defaultSendTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		url := c.oapiURL(fmt.Sprintf("/chat/send?access_token=%s", url2.QueryEscape(accessToken)))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	return err
 }
 
 // getAccessTokenFromDingTalk 从钉钉获取 AccessToken
-func (c *DingTalkClient) getAccessTokenFromDingTalk() (*OAuthTokenResult, error) {
+func (c *DingTalkClient) getAccessTokenFromDingTalk(ctx context.Context) (*OAuthTokenResult, error) {
 	// OpenAPI doc: https://open.dingtalk.com/document/orgapp/obtain-orgapp-token
-	apiUrl := "https://oapi.dingtalk.com/gettoken"
+	apiUrl := c.oapiURL("/gettoken")
 	queryParams := url2.Values{}
 	queryParams.Add("appkey", c.Credential.ClientID)
 	queryParams.Add("appsecret", c.Credential.ClientSecret)
 
-	// Create a new HTTP request to get the AccessToken
-	req, err := http.NewRequest("GET", apiUrl+"?"+queryParams.Encode(), nil)
-	if err != nil {
-		return nil, err
-	}
-
 	// Send the HTTP request and parse the response body as JSON
-	client := http.Client{
-		Timeout: time.Second * 60,
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
+	body, err := c.call(ctx, false, defaultLongTimeout, func(ctx context.Context, _ string) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", apiUrl+"?"+queryParams.Encode(), nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -287,50 +346,34 @@ func (c *DingTalkClient) getAccessTokenFromDingTalk() (*OAuthTokenResult, error)
 	if err != nil {
 		return nil, err
 	}
-	if tokenResult.ErrorCode != 0 {
-		return nil, errors.New(tokenResult.ErrorMessage)
-	}
 	return tokenResult, nil
 }
 
 // GetOpenConversationId 通过 chatId 获取 OpenConversationId
 // 文档: https://open.dingtalk.com/document/development/obtain-group-openconversationid
 func (c *DingTalkClient) GetOpenConversationId(chatID string) (string, error) {
-	accessToken, err := c.GetAccessToken()
-	if err != nil {
-		return "", err
-	}
+	return c.GetOpenConversationIdWithContext(context.Background(), chatID)
+}
 
+// GetOpenConversationIdWithContext 通过 chatId 获取 OpenConversationId，ctx 取消时中断请求
+func (c *DingTalkClient) GetOpenConversationIdWithContext(ctx context.Context, chatID string) (string, error) {
 	// 使用新版 API 地址
-	url := fmt.Sprintf("https://api.dingtalk.com/v1.0/im/chat/%s/convertToOpenConversationId", url2.PathEscape(chatID))
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	// 新版 API 使用 Header 传递 token
-	req.Header.Set("x-acs-dingtalk-access-token", accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
+	url := c.openAPIURL(fmt.Sprintf("/v1.0/im/chat/%s/convertToOpenConversationId", url2.PathEscape(chatID)))
+	bodyBytes, err := c.call(ctx, true, defaultSendTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+		if err != nil {
+			return nil, err
+		}
 
-	bodyBytes, err := io.ReadAll(res.Body)
+		// 新版 API 使用 Header 传递 token
+		req.Header.Set("x-acs-dingtalk-access-token", accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	// 检查 HTTP 状态码
-	if res.StatusCode != 200 {
-		return "", fmt.Errorf("API request failed with status %d: %s", res.StatusCode, string(bodyBytes))
-	}
-
 	result := &OpenConversationIdResult{}
 	if err = json.Unmarshal(bodyBytes, result); err != nil {
 		return "", err
@@ -341,39 +384,105 @@ func (c *DingTalkClient) GetOpenConversationId(chatID string) (string, error) {
 
 // SendWebhookMessage 通过 Webhook URL 发送消息（自定义机器人）
 // webhookURL: 完整的 webhook 地址，例如: https://oapi.dingtalk.com/robot/send?access_token=xxx
-// message: 消息内容，支持 text/markdown/link 等格式
+// message: 消息内容，支持 message 包中的 text/markdown/link/actionCard/feedCard 等类型化消息
 func SendWebhookMessage(webhookURL string, message interface{}) error {
+	return SendWebhookMessageWithContext(context.Background(), webhookURL, message)
+}
+
+// SendWebhookMessageWithContext 通过 Webhook URL 发送消息，ctx 取消时中断请求
+func SendWebhookMessageWithContext(ctx context.Context, webhookURL string, message interface{}) error {
+	return (&DingTalkClient{}).SendWebhookMessageWithContext(ctx, webhookURL, message)
+}
+
+// SendWebhookMessage 通过 Webhook URL 发送消息，使用客户端配置的 HTTP 客户端与超时
+func (c *DingTalkClient) SendWebhookMessage(webhookURL string, message interface{}) error {
+	return c.SendWebhookMessageWithContext(context.Background(), webhookURL, message)
+}
+
+// SendWebhookMessageWithContext 通过 Webhook URL 发送消息，ctx 取消时中断请求
+func (c *DingTalkClient) SendWebhookMessageWithContext(ctx context.Context, webhookURL string, message interface{}) error {
+	if err := validateMessage(message); err != nil {
+		return err
+	}
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(data))
-	if err != nil {
-		return err
+	_, err = c.callSend(ctx, false, defaultSendTimeout, func(ctx context.Context, _ string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	return err
+}
+
+// doRequest 发送 HTTP 请求并读取完整响应体，接口返回错误时返回 *APIError
+// defaultTimeout 为该接口的默认超时，配置 WithTimeout 后以配置为准
+func (c *DingTalkClient) doRequest(req *http.Request, defaultTimeout time.Duration) (*http.Response, []byte, error) {
+	timeout := defaultTimeout
+	if c.timeout > 0 {
+		timeout = c.timeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{
-		Timeout: time.Second * 10,
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
 	}
-	res, err := client.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 
-	var result map[string]interface{}
-	bodyBytes, err := io.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	if err = json.Unmarshal(bodyBytes, &result); err != nil {
-		return err
+	if err = CheckResponse(res, body); err != nil {
+		return res, body, err
+	}
+	return res, body, nil
+}
+
+// oapiURL 拼接旧版 OpenAPI 地址
+func (c *DingTalkClient) oapiURL(path string) string {
+	if c.oapiBaseURL == "" {
+		return DefaultOAPIBaseURL + path
+	}
+	return c.oapiBaseURL + path
+}
+
+// openAPIURL 拼接新版 OpenAPI 地址
+func (c *DingTalkClient) openAPIURL(path string) string {
+	if c.openAPIBaseURL == "" {
+		return DefaultOpenAPIBaseURL + path
 	}
+	return c.openAPIBaseURL + path
+}
 
-	if errcode, ok := result["errcode"].(float64); ok && errcode != 0 {
-		return fmt.Errorf("send webhook message failed: %v", result["errmsg"])
+// validateMessage 消息实现了 Validate 方法（如 message.Message）时在发送前校验
+func validateMessage(message interface{}) error {
+	if v, ok := message.(interface{ Validate() error }); ok {
+		return v.Validate()
 	}
 	return nil
 }
+
+// ErrUnsupportedMessage 消息类型不被接口支持
+var ErrUnsupportedMessage = errors.New("dingtalk: message type is not supported")
+
+// ChatMessageConverter 在 /chat/send 中格式与 Webhook 不同的消息实现该接口，发送前转换
+// 例如 ActionCard 在 /chat/send 中为 action_card 且字段为下划线风格；不支持的类型返回 ErrUnsupportedMessage
+type ChatMessageConverter interface {
+	ToChatMessage() (interface{}, error)
+}
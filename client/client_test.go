@@ -1,7 +1,21 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewDingTalkClient(t *testing.T) {
@@ -57,3 +71,778 @@ func TestNewDingTalkClientManager(t *testing.T) {
 		t.Error("Expected nil for non-existent client, got a client")
 	}
 }
+
+func TestSendWebhookMessageWithContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := SendWebhookMessageWithContext(ctx, server.URL, map[string]interface{}{"msgtype": "text"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		if r.URL.Path != "/gettoken" {
+			t.Errorf("Expected path /gettoken, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("appkey") != "test_client_id" {
+			t.Errorf("Expected appkey test_client_id, got %s", r.URL.Query().Get("appkey"))
+		}
+		w.Write([]byte(`{"errcode":0,"access_token":"token123","expires_in":7200}`))
+	}))
+	defer server.Close()
+
+	client := NewDingTalkClient(
+		Credential{ClientID: "test_client_id", ClientSecret: "test_client_secret"},
+		WithHTTPClient(server.Client()),
+		WithOAPIBaseURL(server.URL+"/"),
+		WithTimeout(time.Second),
+		WithUserAgent("dingtalk-sdk-test"),
+	)
+
+	token, err := client.GetAccessToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token != "token123" {
+		t.Errorf("Expected token token123, got %s", token)
+	}
+	if userAgent != "dingtalk-sdk-test" {
+		t.Errorf("Expected User-Agent dingtalk-sdk-test, got %s", userAgent)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           int
+		body             string
+		tokenExpired     bool
+		rateLimited      bool
+		permissionDenied bool
+	}{
+		{
+			name:         "Invalid access token",
+			status:       http.StatusOK,
+			body:         `{"errcode":40014,"errmsg":"不合法的access_token"}`,
+			tokenExpired: true,
+		},
+		{
+			name:         "Unauthorized openapi",
+			status:       http.StatusUnauthorized,
+			body:         `{"code":"InvalidAuthentication","message":"不合法的access_token"}`,
+			tokenExpired: true,
+		},
+		{
+			name:        "Throttled",
+			status:      http.StatusOK,
+			body:        `{"errcode":90018,"errmsg":"qps limit"}`,
+			rateLimited: true,
+		},
+		{
+			name:             "Permission denied",
+			status:           http.StatusForbidden,
+			body:             `{"code":"Forbidden.AccessDenied.AccessTokenPermissionDenied","message":"denied"}`,
+			permissionDenied: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/gettoken" {
+					w.Write([]byte(`{"errcode":0,"access_token":"token456","expires_in":7200}`))
+					return
+				}
+				w.Header().Set("x-acs-request-id", "req123")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewDingTalkClient(Credential{}, WithOAPIBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+			client.AccessToken = "token123"
+			client.expireAt = time.Now().Unix() + 7200
+
+			err := client.SendRobotMessage("chat123", map[string]interface{}{"msgtype": "text"})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected *APIError, got %v", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, apiErr.StatusCode)
+			}
+			if apiErr.RequestID != "req123" {
+				t.Errorf("Expected request id req123, got %s", apiErr.RequestID)
+			}
+			if apiErr.Endpoint != "/chat/send" {
+				t.Errorf("Expected endpoint /chat/send, got %s", apiErr.Endpoint)
+			}
+			if IsTokenExpired(err) != tt.tokenExpired {
+				t.Errorf("Expected IsTokenExpired %v", tt.tokenExpired)
+			}
+			if IsRateLimited(err) != tt.rateLimited {
+				t.Errorf("Expected IsRateLimited %v", tt.rateLimited)
+			}
+			if IsPermissionDenied(err) != tt.permissionDenied {
+				t.Errorf("Expected IsPermissionDenied %v", tt.permissionDenied)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var tokenCalls, sendCalls int
+	var sentTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gettoken" {
+			tokenCalls++
+			w.Write([]byte(`{"errcode":0,"access_token":"fresh_token","expires_in":7200}`))
+			return
+		}
+		sendCalls++
+		sentTokens = append(sentTokens, r.URL.Query().Get("access_token"))
+		switch sendCalls {
+		case 1:
+			// 缓存的 Token 已被钉钉判定失效
+			w.Write([]byte(`{"errcode":42001,"errmsg":"access_token expired"}`))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 3:
+			w.Write([]byte(`{"errcode":90018,"errmsg":"qps limit"}`))
+		default:
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewDingTalkClient(Credential{}, WithOAPIBaseURL(server.URL), WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Multiplier:     2,
+	}))
+	client.AccessToken = "stale_token"
+	client.expireAt = time.Now().Unix() + 7200
+
+	if err := client.SendRobotMessage("chat123", map[string]interface{}{"msgtype": "text"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tokenCalls != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", tokenCalls)
+	}
+	if sendCalls != 4 {
+		t.Errorf("Expected 4 send attempts, got %d", sendCalls)
+	}
+	if sentTokens[0] != "stale_token" || sentTokens[1] != "fresh_token" {
+		t.Errorf("Expected stale token to be replaced, got %v", sentTokens)
+	}
+}
+
+func TestRetrySendNotDuplicated(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// 服务端已处理，但响应超过客户端超时
+		time.Sleep(time.Millisecond * 100)
+		w.Write([]byte(`{"processQueryKey":"key"}`))
+	}))
+	defer server.Close()
+	client := NewDingTalkClient(Credential{}, WithOpenAPIBaseURL(server.URL), WithTimeout(time.Millisecond*20),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	client.AccessToken = "token123"
+	client.expireAt = time.Now().Unix() + 7200
+	ctx := context.Background()
+
+	_, err := client.SendGroupMessageWithContext(ctx, &GroupMessageRequest{RobotCode: "robot", OpenConversationID: "cid", Message: SampleText{Content: "hi"}})
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected send not to be retried after timeout, got %d attempts", n)
+	}
+
+	// 查询接口仍按策略重试
+	atomic.StoreInt32(&calls, 0)
+	client.QueryGroupMessageReadStatusWithContext(ctx, &GroupReadStatusRequest{RobotCode: "robot", OpenConversationID: "cid", ProcessQueryKey: "key"})
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected query to be retried, got %d attempts", n)
+	}
+
+	// 网关 5xx 无法确认是否已送达，发送接口不重试，查询接口重试
+	atomic.StoreInt32(&calls, 0)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer gateway.Close()
+	client = NewDingTalkClient(Credential{}, WithOAPIBaseURL(gateway.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	client.AccessToken = "token123"
+	client.expireAt = time.Now().Unix() + 7200
+	if err = client.SendRobotMessage("chat123", map[string]interface{}{"msgtype": "text"}); err == nil {
+		t.Fatal("Expected 502 error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected send not to be retried after 502, got %d attempts", n)
+	}
+
+	// 连接被拒绝时请求未发出，发送接口同样重试
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err = http.Get(closed.URL)
+	if err == nil || !isNotSent(err) {
+		t.Errorf("Expected dial error to be treated as not sent, got %v", err)
+	}
+}
+
+func TestGetAccessTokenSingleflight(t *testing.T) {
+	var tokenCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenCalls, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"errcode":0,"access_token":"token123","expires_in":7200}`))
+	}))
+	defer server.Close()
+
+	client := NewDingTalkClient(Credential{}, WithOAPIBaseURL(server.URL))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := client.GetAccessToken()
+			if err != nil || token != "token123" {
+				t.Errorf("Expected token123, got %s (%v)", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&tokenCalls); calls != 1 {
+		t.Errorf("Expected 1 token request, got %d", calls)
+	}
+}
+
+func TestGetAccessTokenRefreshAhead(t *testing.T) {
+	refreshed := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":0,"access_token":"new_token","expires_in":7200}`))
+		once.Do(func() { close(refreshed) })
+	}))
+	defer server.Close()
+
+	client := NewDingTalkClient(Credential{}, WithOAPIBaseURL(server.URL), WithTokenRefreshAhead(5*time.Minute))
+	client.AccessToken = "old_token"
+	client.expireAt = time.Now().Unix() + 120
+
+	token, err := client.GetAccessToken()
+	if err != nil || token != "old_token" {
+		t.Fatalf("Expected cached old_token, got %s (%v)", token, err)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected background refresh")
+	}
+	// 等待后台刷新写入缓存
+	for i := 0; i < 100; i++ {
+		if token, _ = client.GetAccessToken(); token == "new_token" {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("Expected new_token after refresh, got %s", token)
+}
+
+func TestTokenStoreSharedAcrossClients(t *testing.T) {
+	fileStore, err := NewFileTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stores := map[string]TokenStore{
+		"Memory": NewMemoryTokenStore(),
+		"File":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			var tokenCalls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&tokenCalls, 1)
+				w.Write([]byte(`{"errcode":0,"access_token":"shared_token","expires_in":7200}`))
+			}))
+			defer server.Close()
+
+			credential := Credential{ClientID: "client_" + name}
+			replica1 := NewDingTalkClient(credential, WithOAPIBaseURL(server.URL), WithTokenStore(store))
+			replica2 := NewDingTalkClient(credential, WithOAPIBaseURL(server.URL), WithTokenStore(store))
+
+			for _, replica := range []*DingTalkClient{replica1, replica2} {
+				token, err := replica.GetAccessToken()
+				if err != nil || token != "shared_token" {
+					t.Fatalf("Expected shared_token, got %s (%v)", token, err)
+				}
+			}
+			if calls := atomic.LoadInt32(&tokenCalls); calls != 1 {
+				t.Errorf("Expected 1 token request, got %d", calls)
+			}
+
+			unlock, err := store.Lock(context.Background(), "lock_test", time.Minute)
+			if err != nil {
+				t.Fatalf("Expected lock, got %v", err)
+			}
+			if _, err = store.Lock(context.Background(), "lock_test", time.Minute); !errors.Is(err, ErrTokenLocked) {
+				t.Errorf("Expected ErrTokenLocked, got %v", err)
+			}
+			unlock()
+		})
+	}
+}
+
+func TestTokenStoreSameTokenRefreshedAhead(t *testing.T) {
+	var tokenCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenCalls, 1)
+		w.Write([]byte(`{"errcode":0,"access_token":"same_token","expires_in":7200}`))
+	}))
+	defer server.Close()
+
+	store := NewMemoryTokenStore()
+	client := NewDingTalkClient(Credential{ClientID: "client123"}, WithOAPIBaseURL(server.URL), WithTokenStore(store))
+	ctx := context.Background()
+	staleExpireAt := time.Now().Add(2 * time.Minute)
+
+	// 存储中仍是即将过期的那一份时需要自行刷新
+	store.Set(ctx, client.tokenStoreKey(), "same_token", staleExpireAt)
+	if _, _, err := client.fetchAccessToken(ctx, staleExpireAt.Unix()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&tokenCalls); calls != 1 {
+		t.Fatalf("Expected 1 token request, got %d", calls)
+	}
+
+	// 其他副本已刷新，钉钉返回的 Token 字符串相同但过期时间更晚，应直接采用
+	store.Set(ctx, client.tokenStoreKey(), "same_token", time.Now().Add(2*time.Hour))
+	token, expireAt, err := client.fetchAccessToken(ctx, staleExpireAt.Unix())
+	if err != nil || token != "same_token" || !expireAt.After(staleExpireAt) {
+		t.Fatalf("Expected shared same_token, got %s %v (%v)", token, expireAt, err)
+	}
+	if calls := atomic.LoadInt32(&tokenCalls); calls != 1 {
+		t.Errorf("Expected shared token to be reused, got %d token requests", calls)
+	}
+}
+
+func TestFileTokenStoreStaleLock(t *testing.T) {
+	store, err := NewFileTokenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lockPath := store.path("stale") + ".lock"
+	if err = os.WriteFile(lockPath, nil, 0o600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lockPath, old, old)
+
+	// 多个进程同时发现锁过期时只有一个可以获得锁
+	var acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Lock(context.Background(), "stale", time.Minute); err == nil {
+				atomic.AddInt32(&acquired, 1)
+			} else if !errors.Is(err, ErrTokenLocked) {
+				t.Errorf("Expected ErrTokenLocked, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if acquired != 1 {
+		t.Errorf("Expected exactly 1 lock holder, got %d", acquired)
+	}
+	if _, err = os.Stat(lockPath); err != nil {
+		t.Errorf("Expected lock file to be held, got %v", err)
+	}
+}
+
+func TestWebhookRobotSign(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	robot, err := NewWebhookRobotFromURL("https://oapi.dingtalk.com/robot/send?access_token=token123", "SEC123", WithOAPIBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err = robot.Send(map[string]interface{}{"msgtype": "text"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if query.Get("access_token") != "token123" {
+		t.Errorf("Expected access_token token123, got %s", query.Get("access_token"))
+	}
+	if err = VerifySign(query.Get("timestamp"), query.Get("sign"), "SEC123"); err != nil {
+		t.Errorf("Expected valid sign, got %v", err)
+	}
+	if err = VerifySign(query.Get("timestamp"), query.Get("sign"), "OTHER"); !errors.Is(err, ErrInvalidSign) {
+		t.Errorf("Expected ErrInvalidSign, got %v", err)
+	}
+
+	expired := strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixMilli(), 10)
+	if err = VerifySign(expired, query.Get("sign"), "SEC123"); !errors.Is(err, ErrSignExpired) {
+		t.Errorf("Expected ErrSignExpired, got %v", err)
+	}
+}
+
+// newTestClient 创建指向 httptest 服务的客户端，并预置有效的 AccessToken
+func newTestClient(server *httptest.Server) *DingTalkClient {
+	client := NewDingTalkClient(Credential{}, WithOAPIBaseURL(server.URL), WithOpenAPIBaseURL(server.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	client.AccessToken = "token123"
+	client.expireAt = time.Now().Unix() + 7200
+	return client
+}
+
+func TestRobotMessages(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-acs-dingtalk-access-token") != "token123" {
+			t.Errorf("Expected access token header, got %s", r.Header.Get("x-acs-dingtalk-access-token"))
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1.0/robot/groupMessages/send":
+			w.Write([]byte(`{"processQueryKey":"group_key"}`))
+		case "/v1.0/robot/oToMessages/batchSend":
+			w.Write([]byte(`{"processQueryKey":"oto_key","invalidStaffIdList":["bad"]}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	groupResult, err := client.SendGroupMessageWithContext(ctx, &GroupMessageRequest{
+		RobotCode:          "robot123",
+		OpenConversationID: "cid123",
+		Message:            SampleMarkdown{Title: "标题", Text: "内容"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if groupResult.ProcessQueryKey != "group_key" {
+		t.Errorf("Expected group_key, got %s", groupResult.ProcessQueryKey)
+	}
+	if body["msgKey"] != MsgKeySampleMarkdown || body["msgParam"] != `{"title":"标题","text":"内容"}` {
+		t.Errorf("Unexpected request body %v", body)
+	}
+
+	otoResult, err := client.BatchSendOTOMessageWithContext(ctx, &BatchSendOTORequest{
+		RobotCode: "robot123",
+		UserIDs:   []string{"user1", "bad"},
+		Message:   SampleText{Content: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if otoResult.ProcessQueryKey != "oto_key" || len(otoResult.InvalidStaffIDList) != 1 {
+		t.Errorf("Unexpected result %+v", otoResult)
+	}
+
+	_, err = client.BatchSendOTOMessageWithContext(ctx, &BatchSendOTORequest{
+		RobotCode: "robot123",
+		UserIDs:   make([]string, MaxBatchSendUsers+1),
+		Message:   SampleText{Content: "hello"},
+	})
+	if err == nil {
+		t.Error("Expected error for too many userIds")
+	}
+}
+
+func TestRobotMessageStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1.0/robot/oToMessages/readStatus":
+			if r.URL.Query().Get("processQueryKey") != "oto_key" {
+				t.Errorf("Expected processQueryKey oto_key, got %s", r.URL.Query().Get("processQueryKey"))
+			}
+			w.Write([]byte(`{"sendStatus":"SUCCESS","messageReadInfoList":[{"userId":"u1","readStatus":"READ"},{"userId":"u2","readStatus":"UNREAD"}]}`))
+		case "/v1.0/robot/groupMessages/query":
+			if body["nextToken"] == nil {
+				w.Write([]byte(`{"sendStatus":"SUCCESS","readUserIds":["u1"],"unreadUserIds":["u2"],"nextToken":"page2"}`))
+			} else {
+				w.Write([]byte(`{"sendStatus":"SUCCESS","readUserIds":["u3"],"unreadUserIds":[]}`))
+			}
+		case "/v1.0/robot/groupMessages/recall":
+			w.Write([]byte(`{"successResult":["key1"],"failedResult":{"key2":"message too old"}}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	otoStatus, err := client.QueryOTOMessageReadStatusWithContext(ctx, "robot123", "oto_key")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(otoStatus.ReadUserIDs()) != 1 || len(otoStatus.UnreadUserIDs()) != 1 {
+		t.Errorf("Unexpected read status %+v", otoStatus)
+	}
+
+	groupStatus, err := client.QueryGroupMessageReadStatusAllWithContext(ctx, &GroupReadStatusRequest{
+		RobotCode:          "robot123",
+		OpenConversationID: "cid123",
+		ProcessQueryKey:    "group_key",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(groupStatus.ReadUserIDs) != 2 || len(groupStatus.UnreadUserIDs) != 1 {
+		t.Errorf("Unexpected merged status %+v", groupStatus)
+	}
+
+	recall, err := client.RecallGroupMessagesWithContext(ctx, "robot123", "cid123", "key1", "key2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(recall.SuccessResult) != 1 || recall.FailedResult["key2"] == "" {
+		t.Errorf("Unexpected recall result %+v", recall)
+	}
+}
+
+func TestWorkNotification(t *testing.T) {
+	var sendBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "token123" {
+			t.Errorf("Expected access_token token123, got %s", r.URL.Query().Get("access_token"))
+		}
+		switch r.URL.Path {
+		case "/topapi/message/corpconversation/asyncsend_v2":
+			json.NewDecoder(r.Body).Decode(&sendBody)
+			w.Write([]byte(`{"errcode":0,"task_id":256271667526,"request_id":"req123"}`))
+		case "/topapi/message/corpconversation/getsendprogress":
+			w.Write([]byte(`{"errcode":0,"progress":{"progress_in_percent":100,"status":2}}`))
+		case "/topapi/message/corpconversation/getsendresult":
+			w.Write([]byte(`{"errcode":0,"send_result":{"read_user_id_list":["u1"],"unread_user_id_list":["u2"]}}`))
+		case "/topapi/message/corpconversation/recall":
+			w.Write([]byte(`{"errcode":0}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	taskID, err := client.SendWorkNotificationWithContext(ctx, &WorkNotificationRequest{
+		AgentID: 123,
+		UserIDs: []string{"u1", "u2"},
+		DeptIDs: []int64{1, 2},
+		Msg:     map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if taskID != 256271667526 {
+		t.Errorf("Expected task id 256271667526, got %d", taskID)
+	}
+	if sendBody["userid_list"] != "u1,u2" || sendBody["dept_id_list"] != "1,2" {
+		t.Errorf("Unexpected request body %v", sendBody)
+	}
+
+	progress, err := client.GetWorkNotificationProgressWithContext(ctx, 123, taskID)
+	if err != nil || progress.Status != SendProgressFinished {
+		t.Errorf("Unexpected progress %+v (%v)", progress, err)
+	}
+	result, err := client.GetWorkNotificationResultWithContext(ctx, 123, taskID)
+	if err != nil || len(result.ReadUserIDList) != 1 || len(result.UnreadUserIDList) != 1 {
+		t.Errorf("Unexpected result %+v (%v)", result, err)
+	}
+	if err = client.RecallWorkNotificationWithContext(ctx, 123, taskID); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if _, err = client.SendWorkNotificationWithContext(ctx, &WorkNotificationRequest{AgentID: 123, Msg: "x"}); err == nil {
+		t.Error("Expected error without receivers")
+	}
+}
+
+func TestDownloadMessageFile(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 1024)...)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0/robot/messageFiles/download":
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["robotCode"] != "robot1" || body["downloadCode"] != "code1" {
+				t.Errorf("Unexpected request body %v", body)
+			}
+			w.Write([]byte(`{"downloadUrl":"` + server.URL + `/files/a"}`))
+		case "/files/a":
+			if r.Header.Get("x-acs-dingtalk-access-token") != "" {
+				t.Error("Expected download without access token")
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(png)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	result, err := client.DownloadMessageFileWithContext(ctx, "robot1", "code1", buf, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.ContentType != "image/png" || result.Size != int64(len(png)) || !bytes.Equal(buf.Bytes(), png) {
+		t.Errorf("Unexpected result %+v, %d bytes written", result, buf.Len())
+	}
+
+	if _, err = client.DownloadMessageFileWithContext(ctx, "robot1", "code1", &bytes.Buffer{}, 100); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+}
+
+func TestUploadMediaReader(t *testing.T) {
+	var uploads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&uploads, 1)
+		file, header, err := r.FormFile("media")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		if header.Filename != "a.png" || r.FormValue("type") != MediaTypeImage || string(content) != "hello" {
+			t.Errorf("Unexpected upload %s %s %q", header.Filename, r.FormValue("type"), content)
+		}
+		w.Write([]byte(`{"errcode":0,"media_id":"@media1","type":"image"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	var uploaded, total int64
+	result, err := client.UploadMediaReaderWithContext(ctx, strings.NewReader("hello"), "a.png", MediaTypeImage, &UploadOptions{
+		Progress: func(n, size int64) { uploaded, total = n, size },
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.MediaID != "@media1" || uploaded != 5 || total != 5 {
+		t.Errorf("Unexpected result %+v, progress %d/%d", result, uploaded, total)
+	}
+
+	// 非 Seeker 的 Reader 未知大小时在上传过程中校验上限
+	big := io.MultiReader(bytes.NewReader(make([]byte, 3<<20)))
+	if _, err = client.UploadMediaReaderWithContext(ctx, big, "a.mp3", MediaTypeVoice, nil); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+
+	before := atomic.LoadInt32(&uploads)
+	if _, err = client.UploadMediaReaderWithContext(ctx, strings.NewReader("hello"), "a.exe", MediaTypeFile, nil); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("Expected ErrUnsupportedMediaType, got %v", err)
+	}
+	if _, err = client.UploadMediaReaderWithContext(ctx, bytes.NewReader(make([]byte, 3<<20)), "a.amr", MediaTypeVoice, nil); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if atomic.LoadInt32(&uploads) != before {
+		t.Error("Expected validation to fail before sending")
+	}
+
+	// Size 小于实际大小时在上传过程中超限，不按重试策略重传
+	client.retryPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	oversized := &rewindCounter{Reader: bytes.NewReader(make([]byte, 3<<20))}
+	_, err = client.UploadMediaReaderWithContext(ctx, oversized, "a.amr", MediaTypeVoice, &UploadOptions{Size: 100})
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	if oversized.rewinds != 0 {
+		t.Errorf("Expected oversized upload not to be retried, got %d retries", oversized.rewinds)
+	}
+}
+
+// rewindCounter 统计重传时回到起始位置的次数
+type rewindCounter struct {
+	*bytes.Reader
+	rewinds int
+}
+
+func (r *rewindCounter) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		r.rewinds++
+	}
+	return r.Reader.Seek(offset, whence)
+}
+
+func TestUploadFileChunked(t *testing.T) {
+	var chunks []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/file/upload/transaction":
+			if query.Get("chunk_numbers") != "3" || query.Get("file_size") != "10" {
+				t.Errorf("Unexpected transaction query %v", query)
+			}
+			if query.Get("upload_id") == "" {
+				w.Write([]byte(`{"errcode":0,"upload_id":"up1"}`))
+				return
+			}
+			w.Write([]byte(`{"errcode":0,"file_id":"file1"}`))
+		case "/file/upload/chunk":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			chunks = append(chunks, query.Get("chunk_sequence")+":"+string(content))
+			w.Write([]byte(`{"errcode":0}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server)
+
+	fileID, err := client.UploadFileChunkedWithContext(context.Background(), &ChunkedUploadRequest{
+		AgentID:   123,
+		Reader:    strings.NewReader("0123456789"),
+		Size:      10,
+		ChunkSize: 4,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fileID != "file1" || strings.Join(chunks, ",") != "1:0123,2:4567,3:89" {
+		t.Errorf("Unexpected file id %s, chunks %v", fileID, chunks)
+	}
+}
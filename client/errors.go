@@ -0,0 +1,148 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 常见错误码
+// 文档: https://open.dingtalk.com/document/orgapp/server-api-error-codes-1
+const (
+	ErrCodeInvalidCredential  = 40001  // 获取 access_token 时 AppSecret 错误
+	ErrCodeInvalidAccessToken = 40014  // 不合法的 access_token
+	ErrCodeAccessTokenExpired = 42001  // access_token 超时
+	ErrCodeDisabledByAdmin    = 60011  // 管理员权限不足或设置已被禁用
+	ErrCodeIPNotInWhitelist   = 60020  // 访问 IP 不在白名单之中
+	ErrCodeISVTooFrequent     = 90002  // 服务器繁忙，调用过于频繁
+	ErrCodeCorpTooFrequent    = 90005  // 企业调用过于频繁
+	ErrCodeAppTooFrequent     = 90006  // 应用调用过于频繁
+	ErrCodeQPSLimit           = 90018  // 调用接口超过 QPS 限制
+	ErrCodeRobotSendTooFast   = 130101 // 自定义机器人发送过快
+	ErrCodeRobotThrottled     = 410100 // 机器人发送速度太快而被限流
+)
+
+// APIError 钉钉接口返回的错误
+// 旧版接口（oapi）通过 errcode/errmsg 描述错误，新版接口（api）通过 HTTP 状态码与 code/message 描述错误
+type APIError struct {
+	ErrCode    int    // 旧版接口的 errcode
+	Code       string // 新版接口的错误码，例如 InvalidAuthentication
+	ErrMsg     string // 错误描述
+	StatusCode int    // HTTP 状态码
+	RequestID  string // 请求 ID，来自 x-acs-request-id 响应头或响应体
+	Endpoint   string // 接口路径，不含查询参数
+	Body       []byte // 原始响应体
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dingtalk api %s failed: status=%d", e.Endpoint, e.StatusCode)
+	if e.ErrCode != 0 {
+		fmt.Fprintf(&b, ", errcode=%d", e.ErrCode)
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&b, ", code=%s", e.Code)
+	}
+	if e.ErrMsg != "" {
+		fmt.Fprintf(&b, ", errmsg=%s", e.ErrMsg)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, ", request_id=%s", e.RequestID)
+	}
+	return b.String()
+}
+
+// apiErrorBody 兼容新旧两种接口的错误响应体
+type apiErrorBody struct {
+	ErrCode    *int   `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RequestID  string `json:"requestid"`
+	RequestID2 string `json:"request_id"`
+}
+
+// CheckResponse 检查钉钉接口响应，HTTP 状态码非 2xx 或 errcode 非 0 时返回 *APIError
+func CheckResponse(res *http.Response, body []byte) error {
+	parsed := apiErrorBody{}
+	jsonErr := json.Unmarshal(body, &parsed)
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if jsonErr != nil || parsed.ErrCode == nil || *parsed.ErrCode == 0 {
+			return nil
+		}
+	}
+
+	apiErr := &APIError{
+		Code:       parsed.Code,
+		ErrMsg:     parsed.ErrMsg,
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("x-acs-request-id"),
+		Body:       body,
+	}
+	if res.Request != nil && res.Request.URL != nil {
+		apiErr.Endpoint = res.Request.URL.Path
+	}
+	if parsed.ErrCode != nil {
+		apiErr.ErrCode = *parsed.ErrCode
+	}
+	if apiErr.ErrMsg == "" {
+		apiErr.ErrMsg = parsed.Message
+	}
+	if apiErr.ErrMsg == "" && jsonErr != nil {
+		apiErr.ErrMsg = http.StatusText(res.StatusCode)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = parsed.RequestID
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = parsed.RequestID2
+	}
+	return apiErr
+}
+
+// IsTokenExpired 判断错误是否由 access_token 无效或过期引起
+func IsTokenExpired(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case ErrCodeInvalidAccessToken, ErrCodeAccessTokenExpired:
+		return true
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.Code == "InvalidAuthentication"
+}
+
+// IsRateLimited 判断错误是否由接口限流引起
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case ErrCodeISVTooFrequent, ErrCodeCorpTooFrequent, ErrCodeAppTooFrequent, ErrCodeQPSLimit,
+		ErrCodeRobotSendTooFast, ErrCodeRobotThrottled:
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests ||
+		strings.Contains(apiErr.Code, "QpsLimit") || strings.Contains(apiErr.Code, "Throttling")
+}
+
+// IsPermissionDenied 判断错误是否由权限不足引起
+func IsPermissionDenied(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrCode {
+	case ErrCodeDisabledByAdmin, ErrCodeIPNotInWhitelist:
+		return true
+	}
+	if IsRateLimited(err) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusForbidden || strings.HasPrefix(apiErr.Code, "Forbidden")
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	url2 "net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 钉盘分块上传的分块大小
+const (
+	DefaultChunkSize int64 = 5 << 20
+	MaxChunkSize     int64 = 8 << 20
+)
+
+var (
+	// ErrUnsupportedMediaType 媒体类型或文件扩展名不受支持
+	ErrUnsupportedMediaType = errors.New("dingtalk: unsupported media type")
+	// ErrReaderNotReplayable 上传失败后需要重试，但 io.Reader 无法回到起始位置
+	ErrReaderNotReplayable = errors.New("dingtalk: upload reader cannot be replayed")
+)
+
+// MediaLimit 媒体文件的大小与格式限制
+type MediaLimit struct {
+	MaxSize    int64
+	Extensions []string // 小写且不含点
+}
+
+// MediaLimits 各媒体类型的上传限制
+// 文档: https://open.dingtalk.com/document/orgapp/upload-media-files
+var MediaLimits = map[string]MediaLimit{
+	MediaTypeImage: {MaxSize: 20 << 20, Extensions: []string{"jpg", "jpeg", "gif", "png", "bmp"}},
+	MediaTypeVoice: {MaxSize: 2 << 20, Extensions: []string{"amr", "mp3", "wav"}},
+	MediaTypeVideo: {MaxSize: 20 << 20, Extensions: []string{"mp4"}},
+	MediaTypeFile:  {MaxSize: 20 << 20, Extensions: []string{"doc", "docx", "xls", "xlsx", "ppt", "pptx", "zip", "pdf", "rar"}},
+}
+
+// ProgressFunc 上传进度回调，total 未知时为 -1；在上传所在的 goroutine 中调用
+type ProgressFunc func(uploaded, total int64)
+
+// UploadOptions 流式上传选项
+type UploadOptions struct {
+	Size     int64        // 内容大小，小于等于 0 表示未知：r 可 Seek 时自动计算，否则在上传过程中校验大小上限
+	Progress ProgressFunc // 可选
+}
+
+// ValidateMedia 校验媒体类型、文件扩展名与大小，size 小于 0 时不校验大小
+func ValidateMedia(mediaType, filename string, size int64) error {
+	limit, ok := MediaLimits[mediaType]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	supported := false
+	for _, e := range limit.Extensions {
+		if e == ext {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("%w: %s does not accept .%s", ErrUnsupportedMediaType, mediaType, ext)
+	}
+	if size > limit.MaxSize {
+		return fmt.Errorf("%w: %s %d > %d bytes", ErrFileTooLarge, mediaType, size, limit.MaxSize)
+	}
+	return nil
+}
+
+// UploadMediaReader 从 io.Reader 流式上传媒体文件
+func (c *DingTalkClient) UploadMediaReader(r io.Reader, filename, mediaType string, opts *UploadOptions) (*MediaUploadResult, error) {
+	return c.UploadMediaReaderWithContext(context.Background(), r, filename, mediaType, opts)
+}
+
+// UploadMediaReaderWithContext 从 io.Reader 流式上传媒体文件，ctx 取消时中断上传
+// multipart 请求体通过 io.Pipe 边读边发，不在内存中缓存整个文件
+// 上传前按 MediaLimits 校验类型与大小；r 实现 io.Seeker 时失败可重试，否则只尝试一次
+func (c *DingTalkClient) UploadMediaReaderWithContext(ctx context.Context, r io.Reader, filename, mediaType string, opts *UploadOptions) (*MediaUploadResult, error) {
+	var progress ProgressFunc
+	size := int64(-1)
+	if opts != nil {
+		progress = opts.Progress
+		if opts.Size > 0 {
+			size = opts.Size
+		}
+	}
+
+	policy := c.policy()
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		} else if size < 0 {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			size = end - start
+		}
+	}
+	if !seekable {
+		policy.MaxAttempts = 1
+	}
+	if err := ValidateMedia(mediaType, filename, size); err != nil {
+		return nil, err
+	}
+	maxSize := MediaLimits[mediaType].MaxSize
+
+	var done chan struct{}
+	bodyBytes, err := c.callWithPolicy(ctx, policy, true, defaultLongTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		if done != nil {
+			// Token 失效后的重试同样需要重放请求体，等待上一次写入结束后再回到起始位置
+			<-done
+			if !seekable {
+				return nil, ErrReaderNotReplayable
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		done = make(chan struct{})
+
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		go func(done chan struct{}) {
+			defer close(done)
+			part, err := writer.CreateFormFile("media", filename)
+			if err == nil {
+				src := &countingReader{r: r, total: size, max: maxSize, progress: progress}
+				_, err = io.Copy(part, src)
+			}
+			if err == nil {
+				err = writer.WriteField("type", mediaType)
+			}
+			if err == nil {
+				err = writer.Close()
+			}
+			pw.CloseWithError(err)
+		}(done)
+
+		url := c.oapiURL(fmt.Sprintf("/media/upload?access_token=%s", url2.QueryEscape(accessToken)))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, pr)
+		if err != nil {
+			pr.CloseWithError(err)
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	media := &MediaUploadResult{}
+	if err = json.Unmarshal(bodyBytes, media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// ChunkedUploadRequest 钉盘分块上传请求，用于超过 MediaLimits 的大文件
+type ChunkedUploadRequest struct {
+	AgentID   int64
+	Reader    io.Reader
+	Size      int64        // 文件总大小，必填
+	ChunkSize int64        // 分块大小，0 表示 DefaultChunkSize，不能超过 MaxChunkSize
+	Progress  ProgressFunc // 可选，每个分块上传完成后回调
+}
+
+// UploadFileChunked 使用钉盘分块上传接口上传大文件，返回 file_id
+func (c *DingTalkClient) UploadFileChunked(req *ChunkedUploadRequest) (string, error) {
+	return c.UploadFileChunkedWithContext(context.Background(), req)
+}
+
+// UploadFileChunkedWithContext 使用钉盘分块上传接口上传大文件，返回 file_id，ctx 取消时中断请求
+// 每个分块读入内存后上传，失败时按重试策略重传该分块
+// 文档: https://open.dingtalk.com/document/orgapp/enable-multipart-upload-transactions
+func (c *DingTalkClient) UploadFileChunkedWithContext(ctx context.Context, req *ChunkedUploadRequest) (string, error) {
+	if req.AgentID == 0 || req.Reader == nil || req.Size <= 0 {
+		return "", errors.New("dingtalk: agentId, reader and size are required")
+	}
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		return "", fmt.Errorf("dingtalk: chunk size %d exceeds %d bytes", chunkSize, MaxChunkSize)
+	}
+	chunks := (req.Size + chunkSize - 1) / chunkSize
+
+	query := url2.Values{}
+	query.Set("agent_id", strconv.FormatInt(req.AgentID, 10))
+	query.Set("file_size", strconv.FormatInt(req.Size, 10))
+	query.Set("chunk_numbers", strconv.FormatInt(chunks, 10))
+
+	transaction := &struct {
+		UploadID string `json:"upload_id"`
+	}{}
+	if err := c.doOAPIGet(ctx, "/file/upload/transaction", query, transaction); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, chunkSize)
+	var uploaded int64
+	for seq := int64(1); seq <= chunks; seq++ {
+		n, err := io.ReadFull(req.Reader, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("dingtalk: read chunk %d: %w", seq, err)
+		}
+		if err = c.uploadChunk(ctx, req.AgentID, transaction.UploadID, seq, buf[:n]); err != nil {
+			return "", err
+		}
+		uploaded += int64(n)
+		if req.Progress != nil {
+			req.Progress(uploaded, req.Size)
+		}
+	}
+	if uploaded != req.Size {
+		return "", fmt.Errorf("dingtalk: uploaded %d bytes, expected %d", uploaded, req.Size)
+	}
+
+	query.Set("upload_id", transaction.UploadID)
+	result := &struct {
+		FileID string `json:"file_id"`
+	}{}
+	if err := c.doOAPIGet(ctx, "/file/upload/transaction", query, result); err != nil {
+		return "", err
+	}
+	return result.FileID, nil
+}
+
+// uploadChunk 上传单个分块
+func (c *DingTalkClient) uploadChunk(ctx context.Context, agentID int64, uploadID string, seq int64, chunk []byte) error {
+	_, err := c.call(ctx, true, defaultLongTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "chunk"+strconv.FormatInt(seq, 10))
+		if err != nil {
+			return nil, err
+		}
+		if _, err = part.Write(chunk); err != nil {
+			return nil, err
+		}
+		if err = writer.Close(); err != nil {
+			return nil, err
+		}
+
+		query := url2.Values{}
+		query.Set("access_token", accessToken)
+		query.Set("agent_id", strconv.FormatInt(agentID, 10))
+		query.Set("upload_id", uploadID)
+		query.Set("chunk_sequence", strconv.FormatInt(seq, 10))
+		req, err := http.NewRequestWithContext(ctx, "POST", c.oapiURL("/file/upload/chunk?"+query.Encode()), body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
+	return err
+}
+
+// doOAPIGet 以 GET 方式调用旧版 OpenAPI，access_token 通过查询参数传递
+func (c *DingTalkClient) doOAPIGet(ctx context.Context, path string, query url2.Values, result interface{}) error {
+	respBody, err := c.call(ctx, true, defaultSendTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		q := url2.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("access_token", accessToken)
+		return http.NewRequestWithContext(ctx, "GET", c.oapiURL(path+"?"+q.Encode()), nil)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// countingReader 统计已读取字节数，超过上限时返回 ErrFileTooLarge
+type countingReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	max      int64
+	progress ProgressFunc
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		if r.read > r.max {
+			return n, fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, r.max)
+		}
+		if r.progress != nil {
+			r.progress(r.read, r.total)
+		}
+	}
+	return n, err
+}
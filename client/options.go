@@ -0,0 +1,71 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOAPIBaseURL 旧版 OpenAPI 地址
+	DefaultOAPIBaseURL = "https://oapi.dingtalk.com"
+	// DefaultOpenAPIBaseURL 新版 OpenAPI 地址
+	DefaultOpenAPIBaseURL = "https://api.dingtalk.com"
+)
+
+const (
+	// defaultSendTimeout 普通接口调用的默认超时
+	defaultSendTimeout = time.Second * 10
+	// defaultLongTimeout 获取 Token、上传媒体等耗时接口的默认超时
+	defaultLongTimeout = time.Second * 60
+	// defaultRefreshAhead Token 过期前开始后台刷新的默认提前量
+	defaultRefreshAhead = time.Minute * 5
+)
+
+// defaultHTTPClient 未配置 WithHTTPClient 时共享的 HTTP 客户端，超时由每次调用的 context 控制
+var defaultHTTPClient = &http.Client{}
+
+// Option 钉钉客户端配置项
+type Option func(*DingTalkClient)
+
+// WithHTTPClient 使用自定义的 HTTP 客户端，可用于代理、连接池复用或测试
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *DingTalkClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithOAPIBaseURL 设置旧版 OpenAPI 地址，默认 https://oapi.dingtalk.com
+func WithOAPIBaseURL(baseURL string) Option {
+	return func(c *DingTalkClient) {
+		c.oapiBaseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithOpenAPIBaseURL 设置新版 OpenAPI 地址，默认 https://api.dingtalk.com
+func WithOpenAPIBaseURL(baseURL string) Option {
+	return func(c *DingTalkClient) {
+		c.openAPIBaseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTimeout 设置每次调用的超时，覆盖各接口的默认超时（普通接口 10s，Token 与上传 60s）
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *DingTalkClient) {
+		c.timeout = timeout
+	}
+}
+
+// WithUserAgent 设置请求的 User-Agent
+func WithUserAgent(userAgent string) Option {
+	return func(c *DingTalkClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithTokenRefreshAhead 设置 Token 过期前多久开始后台刷新，默认 5 分钟
+func WithTokenRefreshAhead(d time.Duration) Option {
+	return func(c *DingTalkClient) {
+		c.refreshAhead = d
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 重试策略
+// 5xx、网络错误与限流错误按带抖动的指数退避重试；Token 失效错误会刷新 Token 后立即重试一次，不计入 MaxAttempts
+// 发送消息等非幂等接口只重试限流错误与请求发出前的网络错误（如连接失败），
+// 5xx 与超时等无法确认是否送达的错误直接返回，由调用方决定是否重发
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数（含首次），小于等于 1 表示不重试
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待时间上限
+	Multiplier     float64       // 退避倍数
+	Jitter         float64       // 抖动比例，取值 0~1，实际等待时间在 [1-Jitter, 1+Jitter] 倍之间浮动
+
+	nonIdempotent bool // 由 callSend 设置
+}
+
+// DefaultRetryPolicy 默认重试策略：最多尝试 3 次，退避 200ms 起，上限 5s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond * 200,
+		MaxBackoff:     time.Second * 5,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// WithRetryPolicy 设置重试策略，传入 RetryPolicy{MaxAttempts: 1} 可关闭退避重试
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *DingTalkClient) {
+		c.retryPolicy = &policy
+	}
+}
+
+// backoff 计算第 attempt 次重试前的等待时间，attempt 从 1 开始
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff *= 1 - p.Jitter + rand.Float64()*2*p.Jitter
+	}
+	return time.Duration(backoff)
+}
+
+// isRetryable 判断错误是否可以退避重试，nonIdempotent 为 true 时只重试限流与请求未发出的情况
+func isRetryable(ctx context.Context, err error, nonIdempotent bool) bool {
+	if ctx.Err() != nil {
+		// 调用方已取消或超时
+		return false
+	}
+	if errors.Is(err, ErrFileTooLarge) {
+		// 请求体超过上限，重传同样会失败
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if nonIdempotent {
+			// 网关返回的 5xx 无法确认服务端是否已处理
+			return IsRateLimited(err)
+		}
+		return apiErr.StatusCode >= http.StatusInternalServerError || IsRateLimited(err)
+	}
+	if nonIdempotent {
+		return isNotSent(err)
+	}
+	// 网络错误或单次请求超时
+	return true
+}
+
+// isNotSent 判断网络错误是否发生在请求发出之前：域名解析失败、建立连接失败或连接被拒绝
+func isNotSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// buildRequestFunc 构造一次请求，每次尝试都会重新调用以携带最新的 access_token 并重建请求体
+type buildRequestFunc func(ctx context.Context, accessToken string) (*http.Request, error)
+
+// call 按重试策略执行接口调用并返回响应体
+// authenticated 为 true 时自动获取 access_token，遇到 Token 失效错误时使缓存失效并重试一次
+func (c *DingTalkClient) call(ctx context.Context, authenticated bool, defaultTimeout time.Duration, build buildRequestFunc) ([]byte, error) {
+	return c.callWithPolicy(ctx, c.policy(), authenticated, defaultTimeout, build)
+}
+
+// callSend 执行发送消息等非幂等的调用，5xx 与超时等无法确认服务端是否已处理的错误不重试
+func (c *DingTalkClient) callSend(ctx context.Context, authenticated bool, defaultTimeout time.Duration, build buildRequestFunc) ([]byte, error) {
+	policy := c.policy()
+	policy.nonIdempotent = true
+	return c.callWithPolicy(ctx, policy, authenticated, defaultTimeout, build)
+}
+
+// policy 返回客户端配置的重试策略
+func (c *DingTalkClient) policy() RetryPolicy {
+	if c.retryPolicy != nil {
+		return *c.retryPolicy
+	}
+	return DefaultRetryPolicy()
+}
+
+// callWithPolicy 按指定的重试策略执行接口调用，用于请求体无法重放等需要覆盖客户端策略的场景
+func (c *DingTalkClient) callWithPolicy(ctx context.Context, policy RetryPolicy, authenticated bool, defaultTimeout time.Duration, build buildRequestFunc) ([]byte, error) {
+	tokenRefreshed := false
+	for attempt := 1; ; attempt++ {
+		accessToken := ""
+		if authenticated {
+			var err error
+			accessToken, err = c.GetAccessTokenWithContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if accessToken == "" {
+				return nil, errors.New("empty access token")
+			}
+		}
+
+		req, err := build(ctx, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		_, body, err := c.doRequest(req, defaultTimeout)
+		if err == nil {
+			return body, nil
+		}
+
+		if authenticated && !tokenRefreshed && IsTokenExpired(err) {
+			tokenRefreshed = true
+			c.InvalidateAccessTokenIfCurrent(accessToken)
+			attempt--
+			continue
+		}
+		if attempt >= policy.MaxAttempts || !isRetryable(ctx, err, policy.nonIdempotent) {
+			return body, err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return body, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// MaxBatchSendUsers 单次批量发送单聊消息的最大用户数
+const MaxBatchSendUsers = 20
+
+// 企业机器人消息模板
+// 文档: https://open.dingtalk.com/document/orgapp/types-of-messages-sent-by-robots
+const (
+	MsgKeySampleText       = "sampleText"
+	MsgKeySampleMarkdown   = "sampleMarkdown"
+	MsgKeySampleLink       = "sampleLink"
+	MsgKeySampleActionCard = "sampleActionCard"
+	MsgKeySampleImageMsg   = "sampleImageMsg"
+	MsgKeySampleFile       = "sampleFile"
+)
+
+// RobotMessage 企业机器人消息模板，序列化后的 JSON 作为 msgParam 发送
+type RobotMessage interface {
+	MsgKey() string
+}
+
+// SampleText 文本消息
+type SampleText struct {
+	Content string `json:"content"`
+}
+
+// MsgKey 消息模板
+func (SampleText) MsgKey() string { return MsgKeySampleText }
+
+// SampleMarkdown Markdown 消息
+type SampleMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// MsgKey 消息模板
+func (SampleMarkdown) MsgKey() string { return MsgKeySampleMarkdown }
+
+// SampleLink 链接消息
+type SampleLink struct {
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	MessageURL string `json:"messageUrl"`
+	PicURL     string `json:"picUrl"`
+}
+
+// MsgKey 消息模板
+func (SampleLink) MsgKey() string { return MsgKeySampleLink }
+
+// SampleActionCard 整体跳转 ActionCard 消息
+type SampleActionCard struct {
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	SingleTitle string `json:"singleTitle"`
+	SingleURL   string `json:"singleURL"`
+}
+
+// MsgKey 消息模板
+func (SampleActionCard) MsgKey() string { return MsgKeySampleActionCard }
+
+// SampleImageMsg 图片消息，PhotoURL 可以是图片链接或 UploadMedia 返回的 media_id
+type SampleImageMsg struct {
+	PhotoURL string `json:"photoURL"`
+}
+
+// MsgKey 消息模板
+func (SampleImageMsg) MsgKey() string { return MsgKeySampleImageMsg }
+
+// SampleFile 文件消息，MediaID 为 UploadMedia 返回的 media_id
+type SampleFile struct {
+	MediaID  string `json:"mediaId"`
+	FileName string `json:"fileName"`
+	FileType string `json:"fileType"`
+}
+
+// MsgKey 消息模板
+func (SampleFile) MsgKey() string { return MsgKeySampleFile }
+
+// GroupMessageRequest 机器人发送群聊消息请求
+type GroupMessageRequest struct {
+	RobotCode          string       // 机器人编码
+	OpenConversationID string       // 群的 openConversationId
+	CoolAppCode        string       // 酷应用编码，可选
+	Message            RobotMessage // 消息内容
+}
+
+// SendGroupMessageResult 机器人发送群聊消息结果
+type SendGroupMessageResult struct {
+	ProcessQueryKey string `json:"processQueryKey"` // 用于查询已读状态与撤回
+}
+
+// BatchSendOTORequest 机器人批量发送单聊消息请求
+type BatchSendOTORequest struct {
+	RobotCode string       // 机器人编码
+	UserIDs   []string     // 接收人 userId，最多 MaxBatchSendUsers 个
+	Message   RobotMessage // 消息内容
+}
+
+// BatchSendOTOResult 机器人批量发送单聊消息结果
+type BatchSendOTOResult struct {
+	ProcessQueryKey           string   `json:"processQueryKey"`           // 用于查询已读状态与撤回
+	InvalidStaffIDList        []string `json:"invalidStaffIdList"`        // 无效的 userId
+	FlowControlledStaffIDList []string `json:"flowControlledStaffIdList"` // 被流控的 userId
+}
+
+// SendGroupMessage 机器人发送群聊消息
+func (c *DingTalkClient) SendGroupMessage(req *GroupMessageRequest) (*SendGroupMessageResult, error) {
+	return c.SendGroupMessageWithContext(context.Background(), req)
+}
+
+// SendGroupMessageWithContext 机器人发送群聊消息，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/the-robot-sends-a-group-message
+func (c *DingTalkClient) SendGroupMessageWithContext(ctx context.Context, req *GroupMessageRequest) (*SendGroupMessageResult, error) {
+	if req.RobotCode == "" || req.OpenConversationID == "" {
+		return nil, errors.New("dingtalk: robotCode and openConversationId are required")
+	}
+	msgKey, msgParam, err := encodeRobotMessage(req.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"robotCode":          req.RobotCode,
+		"openConversationId": req.OpenConversationID,
+		"msgKey":             msgKey,
+		"msgParam":           msgParam,
+	}
+	if req.CoolAppCode != "" {
+		body["coolAppCode"] = req.CoolAppCode
+	}
+
+	result := &SendGroupMessageResult{}
+	if err = c.sendOpenAPI(ctx, "/v1.0/robot/groupMessages/send", body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BatchSendOTOMessage 机器人批量发送单聊消息
+func (c *DingTalkClient) BatchSendOTOMessage(req *BatchSendOTORequest) (*BatchSendOTOResult, error) {
+	return c.BatchSendOTOMessageWithContext(context.Background(), req)
+}
+
+// BatchSendOTOMessageWithContext 机器人批量发送单聊消息，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/chatbots-send-one-on-one-chat-messages-in-batches
+func (c *DingTalkClient) BatchSendOTOMessageWithContext(ctx context.Context, req *BatchSendOTORequest) (*BatchSendOTOResult, error) {
+	if req.RobotCode == "" || len(req.UserIDs) == 0 {
+		return nil, errors.New("dingtalk: robotCode and userIds are required")
+	}
+	if len(req.UserIDs) > MaxBatchSendUsers {
+		return nil, fmt.Errorf("dingtalk: at most %d userIds per batch, got %d", MaxBatchSendUsers, len(req.UserIDs))
+	}
+	msgKey, msgParam, err := encodeRobotMessage(req.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"robotCode": req.RobotCode,
+		"userIds":   req.UserIDs,
+		"msgKey":    msgKey,
+		"msgParam":  msgParam,
+	}
+
+	result := &BatchSendOTOResult{}
+	if err = c.sendOpenAPI(ctx, "/v1.0/robot/oToMessages/batchSend", body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// encodeRobotMessage 将消息模板编码为 msgKey 与 msgParam
+func encodeRobotMessage(message RobotMessage) (string, string, error) {
+	if message == nil {
+		return "", "", errors.New("dingtalk: message is required")
+	}
+	if err := validateMessage(message); err != nil {
+		return "", "", err
+	}
+	param, err := json.Marshal(message)
+	if err != nil {
+		return "", "", err
+	}
+	return message.MsgKey(), string(param), nil
+}
+
+// callFunc 执行接口调用，c.call 或 c.callSend
+type callFunc func(ctx context.Context, authenticated bool, defaultTimeout time.Duration, build buildRequestFunc) ([]byte, error)
+
+// doOpenAPI 调用新版 OpenAPI，请求体与响应体均为 JSON，body 或 result 为 nil 时忽略
+func (c *DingTalkClient) doOpenAPI(ctx context.Context, method, path string, body, result interface{}) error {
+	return c.openAPI(ctx, c.call, method, path, body, result)
+}
+
+// sendOpenAPI 以 POST 方式调用新版 OpenAPI 的发送接口，无法确认是否送达的网络错误不重试
+func (c *DingTalkClient) sendOpenAPI(ctx context.Context, path string, body, result interface{}) error {
+	return c.openAPI(ctx, c.callSend, "POST", path, body, result)
+}
+
+func (c *DingTalkClient) openAPI(ctx context.Context, call callFunc, method, path string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	respBody, err := call(ctx, true, defaultSendTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.openAPIURL(path), reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-acs-dingtalk-access-token", accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
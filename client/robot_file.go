@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// DefaultMaxDownloadSize 未指定上限时下载文件的默认大小上限
+const DefaultMaxDownloadSize int64 = 100 << 20
+
+// sniffLen 识别内容类型时读取的字节数
+const sniffLen = 512
+
+// ErrFileTooLarge 文件超过大小上限
+var ErrFileTooLarge = errors.New("dingtalk: file exceeds size limit")
+
+// DownloadResult 文件下载结果
+type DownloadResult struct {
+	ContentType string // 响应头中的类型，缺失或为 application/octet-stream 时根据内容识别
+	Size        int64  // 实际写入的字节数
+}
+
+// GetMessageFileDownloadURL 使用机器人接收消息中的 downloadCode 换取临时下载链接
+func (c *DingTalkClient) GetMessageFileDownloadURL(robotCode, downloadCode string) (string, error) {
+	return c.GetMessageFileDownloadURLWithContext(context.Background(), robotCode, downloadCode)
+}
+
+// GetMessageFileDownloadURLWithContext 使用机器人接收消息中的 downloadCode 换取临时下载链接，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/download-the-file-content-of-the-robot-receiving-message
+func (c *DingTalkClient) GetMessageFileDownloadURLWithContext(ctx context.Context, robotCode, downloadCode string) (string, error) {
+	if robotCode == "" || downloadCode == "" {
+		return "", errors.New("dingtalk: robotCode and downloadCode are required")
+	}
+	body := map[string]interface{}{
+		"robotCode":    robotCode,
+		"downloadCode": downloadCode,
+	}
+
+	result := &struct {
+		DownloadURL string `json:"downloadUrl"`
+	}{}
+	if err := c.doOpenAPI(ctx, "POST", "/v1.0/robot/messageFiles/download", body, result); err != nil {
+		return "", err
+	}
+	if result.DownloadURL == "" {
+		return "", errors.New("dingtalk: empty downloadUrl")
+	}
+	return result.DownloadURL, nil
+}
+
+// DownloadMessageFile 下载机器人接收消息中的文件并写入 w
+func (c *DingTalkClient) DownloadMessageFile(robotCode, downloadCode string, w io.Writer, maxSize int64) (*DownloadResult, error) {
+	return c.DownloadMessageFileWithContext(context.Background(), robotCode, downloadCode, w, maxSize)
+}
+
+// DownloadMessageFileWithContext 下载机器人接收消息中的文件并写入 w，ctx 取消时中断请求
+// maxSize 为大小上限，0 表示使用 DefaultMaxDownloadSize；超过上限时返回 ErrFileTooLarge，此时 w 中可能已写入部分内容
+func (c *DingTalkClient) DownloadMessageFileWithContext(ctx context.Context, robotCode, downloadCode string, w io.Writer, maxSize int64) (*DownloadResult, error) {
+	downloadURL, err := c.GetMessageFileDownloadURLWithContext(ctx, robotCode, downloadCode)
+	if err != nil {
+		return nil, err
+	}
+	return c.download(ctx, downloadURL, w, maxSize)
+}
+
+// download 流式下载临时链接，不携带 access_token
+func (c *DingTalkClient) download(ctx context.Context, downloadURL string, w io.Writer, maxSize int64) (*DownloadResult, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDownloadSize
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, sniffLen))
+		return nil, CheckResponse(res, body)
+	}
+	if res.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrFileTooLarge, res.ContentLength, maxSize)
+	}
+
+	// 先读取开头用于识别类型，再与剩余内容一起写出
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(res.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	result := &DownloadResult{ContentType: res.Header.Get("Content-Type")}
+	if mediaType, _, _ := mime.ParseMediaType(result.ContentType); mediaType == "" || mediaType == "application/octet-stream" {
+		result.ContentType = http.DetectContentType(head)
+	}
+
+	result.Size, err = io.Copy(w, io.MultiReader(bytes.NewReader(head), io.LimitReader(res.Body, maxSize+1-int64(n))))
+	if err != nil {
+		return result, err
+	}
+	if result.Size > maxSize {
+		return result, fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, maxSize)
+	}
+	return result, nil
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"errors"
+	url2 "net/url"
+)
+
+// 消息已读状态
+const (
+	ReadStatusRead   = "READ"
+	ReadStatusUnread = "UNREAD"
+)
+
+// MessageReadInfo 单个用户的已读信息
+type MessageReadInfo struct {
+	Name          string `json:"name"`
+	UserID        string `json:"userId"`
+	ReadStatus    string `json:"readStatus"`    // READ 或 UNREAD
+	ReadTimestamp int64  `json:"readTimestamp"` // 已读时间，毫秒
+}
+
+// OTOReadStatusResult 单聊消息已读状态
+type OTOReadStatusResult struct {
+	SendStatus          string            `json:"sendStatus"`
+	MessageReadInfoList []MessageReadInfo `json:"messageReadInfoList"`
+}
+
+// ReadUserIDs 已读用户
+func (r *OTOReadStatusResult) ReadUserIDs() []string {
+	return r.userIDs(ReadStatusRead)
+}
+
+// UnreadUserIDs 未读用户
+func (r *OTOReadStatusResult) UnreadUserIDs() []string {
+	return r.userIDs(ReadStatusUnread)
+}
+
+func (r *OTOReadStatusResult) userIDs(status string) []string {
+	var userIDs []string
+	for _, info := range r.MessageReadInfoList {
+		if info.ReadStatus == status {
+			userIDs = append(userIDs, info.UserID)
+		}
+	}
+	return userIDs
+}
+
+// GroupReadStatusRequest 群聊消息已读状态查询请求
+type GroupReadStatusRequest struct {
+	RobotCode          string
+	OpenConversationID string
+	ProcessQueryKey    string
+	MaxResults         int    // 每页数量，0 表示使用钉钉默认值
+	NextToken          string // 分页游标，首页为空
+}
+
+// GroupReadStatusResult 群聊消息已读状态
+type GroupReadStatusResult struct {
+	SendStatus    string   `json:"sendStatus"`
+	ReadUserIDs   []string `json:"readUserIds"`
+	UnreadUserIDs []string `json:"unreadUserIds"`
+	NextToken     string   `json:"nextToken"` // 为空表示没有更多数据
+}
+
+// RecallResult 撤回结果
+type RecallResult struct {
+	SuccessResult []string          `json:"successResult"` // 撤回成功的 processQueryKey
+	FailedResult  map[string]string `json:"failedResult"`  // 撤回失败的 processQueryKey 及原因
+}
+
+// QueryOTOMessageReadStatus 查询机器人单聊消息已读状态
+func (c *DingTalkClient) QueryOTOMessageReadStatus(robotCode, processQueryKey string) (*OTOReadStatusResult, error) {
+	return c.QueryOTOMessageReadStatusWithContext(context.Background(), robotCode, processQueryKey)
+}
+
+// QueryOTOMessageReadStatusWithContext 查询机器人单聊消息已读状态，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/chatbots-batch-query-the-read-status-of-one-on-one-chat-messages
+func (c *DingTalkClient) QueryOTOMessageReadStatusWithContext(ctx context.Context, robotCode, processQueryKey string) (*OTOReadStatusResult, error) {
+	if robotCode == "" || processQueryKey == "" {
+		return nil, errors.New("dingtalk: robotCode and processQueryKey are required")
+	}
+	query := url2.Values{}
+	query.Set("robotCode", robotCode)
+	query.Set("processQueryKey", processQueryKey)
+
+	result := &OTOReadStatusResult{}
+	if err := c.doOpenAPI(ctx, "GET", "/v1.0/robot/oToMessages/readStatus?"+query.Encode(), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryGroupMessageReadStatus 分页查询机器人群聊消息已读状态，返回当前页
+func (c *DingTalkClient) QueryGroupMessageReadStatus(req *GroupReadStatusRequest) (*GroupReadStatusResult, error) {
+	return c.QueryGroupMessageReadStatusWithContext(context.Background(), req)
+}
+
+// QueryGroupMessageReadStatusWithContext 分页查询机器人群聊消息已读状态，返回当前页，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/query-the-read-status-of-robot-group-messages
+func (c *DingTalkClient) QueryGroupMessageReadStatusWithContext(ctx context.Context, req *GroupReadStatusRequest) (*GroupReadStatusResult, error) {
+	if req.RobotCode == "" || req.OpenConversationID == "" || req.ProcessQueryKey == "" {
+		return nil, errors.New("dingtalk: robotCode, openConversationId and processQueryKey are required")
+	}
+	body := map[string]interface{}{
+		"robotCode":          req.RobotCode,
+		"openConversationId": req.OpenConversationID,
+		"processQueryKey":    req.ProcessQueryKey,
+	}
+	if req.MaxResults > 0 {
+		body["maxResults"] = req.MaxResults
+	}
+	if req.NextToken != "" {
+		body["nextToken"] = req.NextToken
+	}
+
+	result := &GroupReadStatusResult{}
+	if err := c.doOpenAPI(ctx, "POST", "/v1.0/robot/groupMessages/query", body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryGroupMessageReadStatusAll 查询机器人群聊消息已读状态，自动翻页并合并已读与未读用户
+func (c *DingTalkClient) QueryGroupMessageReadStatusAll(req *GroupReadStatusRequest) (*GroupReadStatusResult, error) {
+	return c.QueryGroupMessageReadStatusAllWithContext(context.Background(), req)
+}
+
+// QueryGroupMessageReadStatusAllWithContext 查询机器人群聊消息已读状态，自动翻页并合并已读与未读用户，ctx 取消时中断请求
+func (c *DingTalkClient) QueryGroupMessageReadStatusAllWithContext(ctx context.Context, req *GroupReadStatusRequest) (*GroupReadStatusResult, error) {
+	page := *req
+	merged := &GroupReadStatusResult{}
+	for {
+		result, err := c.QueryGroupMessageReadStatusWithContext(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		merged.SendStatus = result.SendStatus
+		merged.ReadUserIDs = append(merged.ReadUserIDs, result.ReadUserIDs...)
+		merged.UnreadUserIDs = append(merged.UnreadUserIDs, result.UnreadUserIDs...)
+		if result.NextToken == "" || result.NextToken == page.NextToken {
+			return merged, nil
+		}
+		page.NextToken = result.NextToken
+	}
+}
+
+// RecallOTOMessages 批量撤回机器人单聊消息
+func (c *DingTalkClient) RecallOTOMessages(robotCode string, processQueryKeys ...string) (*RecallResult, error) {
+	return c.RecallOTOMessagesWithContext(context.Background(), robotCode, processQueryKeys...)
+}
+
+// RecallOTOMessagesWithContext 批量撤回机器人单聊消息，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/batch-message-recall-chat
+func (c *DingTalkClient) RecallOTOMessagesWithContext(ctx context.Context, robotCode string, processQueryKeys ...string) (*RecallResult, error) {
+	if robotCode == "" || len(processQueryKeys) == 0 {
+		return nil, errors.New("dingtalk: robotCode and processQueryKeys are required")
+	}
+	body := map[string]interface{}{
+		"robotCode":        robotCode,
+		"processQueryKeys": processQueryKeys,
+	}
+
+	result := &RecallResult{}
+	if err := c.doOpenAPI(ctx, "POST", "/v1.0/robot/otoMessages/batchRecall", body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecallGroupMessages 撤回机器人群聊消息
+func (c *DingTalkClient) RecallGroupMessages(robotCode, openConversationID string, processQueryKeys ...string) (*RecallResult, error) {
+	return c.RecallGroupMessagesWithContext(context.Background(), robotCode, openConversationID, processQueryKeys...)
+}
+
+// RecallGroupMessagesWithContext 撤回机器人群聊消息，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/enterprise-chatbot-withdraws-internal-group-messages
+func (c *DingTalkClient) RecallGroupMessagesWithContext(ctx context.Context, robotCode, openConversationID string, processQueryKeys ...string) (*RecallResult, error) {
+	if robotCode == "" || openConversationID == "" || len(processQueryKeys) == 0 {
+		return nil, errors.New("dingtalk: robotCode, openConversationId and processQueryKeys are required")
+	}
+	body := map[string]interface{}{
+		"robotCode":          robotCode,
+		"openConversationId": openConversationID,
+		"processQueryKeys":   processQueryKeys,
+	}
+
+	result := &RecallResult{}
+	if err := c.doOpenAPI(ctx, "POST", "/v1.0/robot/groupMessages/recall", body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
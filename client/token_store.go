@@ -0,0 +1,353 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/internal/fileutil"
+	"github.com/difyz9/dingtalk-sdk.git/internal/resp"
+)
+
+// ErrTokenLocked 刷新锁已被其他进程持有
+var ErrTokenLocked = errors.New("dingtalk: token refresh lock is held by another process")
+
+// TokenStore 跨进程共享 AccessToken 的存储
+// 多副本部署时配置同一个 TokenStore，只有获得刷新锁的副本会请求钉钉，其他副本读取共享的 Token
+type TokenStore interface {
+	// Get 读取 Token，不存在时返回空字符串
+	Get(ctx context.Context, key string) (accessToken string, expireAt time.Time, err error)
+	// Set 写入 Token 及其过期时间
+	Set(ctx context.Context, key, accessToken string, expireAt time.Time) error
+	// Lock 尝试获取刷新锁，锁被占用时返回 ErrTokenLocked；ttl 到期后锁自动释放，防止持有者崩溃导致死锁
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+}
+
+const (
+	// tokenLockTTL 刷新锁的有效期，应大于一次 Token 请求的耗时
+	tokenLockTTL = time.Second * 30
+	// tokenWaitInterval 未获得锁时轮询共享 Token 的间隔
+	tokenWaitInterval = time.Millisecond * 200
+	// tokenRefreshTimeout 一次后台刷新的总耗时上限，包括等待其他副本与请求钉钉
+	tokenRefreshTimeout = tokenLockTTL + defaultLongTimeout
+)
+
+// WithTokenStore 使用共享的 Token 存储
+func WithTokenStore(store TokenStore) Option {
+	return func(c *DingTalkClient) {
+		c.tokenStore = store
+	}
+}
+
+// tokenStoreKey Token 在存储中的键
+func (c *DingTalkClient) tokenStoreKey() string {
+	return "dingtalk:access_token:" + c.Credential.ClientID
+}
+
+// fetchAccessToken 获取新的 Token，配置了 TokenStore 时优先使用其他副本刷新的 Token
+// staleExpireAt 为需要被替换的 Token（即将过期或已被钉钉判定无效）的过期时间（Unix 秒），
+// 钉钉在有效期内会返回相同的 Token 字符串，因此只采用过期时间更晚的共享 Token
+func (c *DingTalkClient) fetchAccessToken(ctx context.Context, staleExpireAt int64) (string, time.Time, error) {
+	if c.tokenStore == nil {
+		return c.fetchAccessTokenFromDingTalk(ctx)
+	}
+
+	key := c.tokenStoreKey()
+	deadline := time.Now().Add(tokenLockTTL)
+	for {
+		if accessToken, expireAt, ok := c.loadSharedToken(ctx, key, staleExpireAt); ok {
+			return accessToken, expireAt, nil
+		}
+
+		unlock, err := c.tokenStore.Lock(ctx, key, tokenLockTTL)
+		if err == nil {
+			defer unlock()
+			// 获得锁后再检查一次，其他副本可能刚刚完成刷新
+			if accessToken, expireAt, ok := c.loadSharedToken(ctx, key, staleExpireAt); ok {
+				return accessToken, expireAt, nil
+			}
+			accessToken, expireAt, err := c.fetchAccessTokenFromDingTalk(ctx)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			if err = c.tokenStore.Set(ctx, key, accessToken, expireAt); err != nil {
+				return "", time.Time{}, err
+			}
+			return accessToken, expireAt, nil
+		}
+		if !errors.Is(err, ErrTokenLocked) || time.Now().After(deadline) {
+			// 存储不可用或持锁副本迟迟未完成刷新，退化为自行刷新
+			return c.fetchAccessTokenFromDingTalk(ctx)
+		}
+
+		timer := time.NewTimer(tokenWaitInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", time.Time{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// loadSharedToken 从存储读取可用的 Token，过期时间不晚于 staleExpireAt 的视为同一次刷新的结果，不会采用
+func (c *DingTalkClient) loadSharedToken(ctx context.Context, key string, staleExpireAt int64) (string, time.Time, bool) {
+	accessToken, expireAt, err := c.tokenStore.Get(ctx, key)
+	if err != nil || accessToken == "" || expireAt.Unix() <= staleExpireAt {
+		return "", time.Time{}, false
+	}
+	// 与本地缓存一样预留一分钟有效期
+	if time.Now().Add(time.Minute).After(expireAt) {
+		return "", time.Time{}, false
+	}
+	return accessToken, expireAt, true
+}
+
+// fetchAccessTokenFromDingTalk 直接请求钉钉获取 Token
+func (c *DingTalkClient) fetchAccessTokenFromDingTalk(ctx context.Context) (string, time.Time, error) {
+	tokenResult, err := c.getAccessTokenFromDingTalk(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenResult.AccessToken, time.Now().Add(time.Duration(tokenResult.ExpiresIn) * time.Second), nil
+}
+
+// MemoryTokenStore 进程内 Token 存储，可在同一进程的多个客户端间共享
+type MemoryTokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string]storedToken
+	locks  map[string]time.Time
+}
+
+type storedToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpireAt    time.Time `json:"expire_at"`
+}
+
+// NewMemoryTokenStore 创建进程内 Token 存储
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]storedToken),
+		locks:  make(map[string]time.Time),
+	}
+}
+
+// Get 读取 Token
+func (s *MemoryTokenStore) Get(ctx context.Context, key string) (string, time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token := s.tokens[key]
+	return token.AccessToken, token.ExpireAt, nil
+}
+
+// Set 写入 Token
+func (s *MemoryTokenStore) Set(ctx context.Context, key, accessToken string, expireAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens[key] = storedToken{AccessToken: accessToken, ExpireAt: expireAt}
+	return nil
+}
+
+// Lock 获取刷新锁
+func (s *MemoryTokenStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if lockedUntil, ok := s.locks[key]; ok && time.Now().Before(lockedUntil) {
+		return nil, ErrTokenLocked
+	}
+	lockedUntil := time.Now().Add(ttl)
+	s.locks[key] = lockedUntil
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.locks[key] == lockedUntil {
+			delete(s.locks, key)
+		}
+	}, nil
+}
+
+// FileTokenStore 基于文件的 Token 存储，适用于同一台机器上的多个进程
+// 每个键对应目录下的一个 JSON 文件，刷新锁通过独占创建 .lock 文件实现
+type FileTokenStore struct {
+	dir string
+}
+
+// NewFileTokenStore 创建文件 Token 存储，dir 不存在时自动创建
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{dir: dir}, nil
+}
+
+// path 键对应的文件路径
+func (s *FileTokenStore) path(key string) string {
+	return fileutil.Path(s.dir, key, ".json")
+}
+
+// Get 读取 Token
+func (s *FileTokenStore) Get(ctx context.Context, key string) (string, time.Time, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token := storedToken{}
+	if err = json.Unmarshal(data, &token); err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, token.ExpireAt, nil
+}
+
+// Set 写入 Token
+func (s *FileTokenStore) Set(ctx context.Context, key, accessToken string, expireAt time.Time) error {
+	data, err := json.Marshal(storedToken{AccessToken: accessToken, ExpireAt: expireAt})
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFile(s.path(key), data)
+}
+
+// Lock 获取刷新锁，超过 ttl 未释放的锁文件视为持有者已崩溃并被清理
+func (s *FileTokenStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	lockPath := s.path(key) + ".lock"
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			info, err := f.Stat()
+			f.Close()
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, err
+			}
+			return func() { removeLockFile(lockPath, info) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		info, statErr := os.Stat(lockPath)
+		if statErr != nil || time.Since(info.ModTime()) < ttl {
+			break
+		}
+		// 多个进程可能同时发现锁已过期，只有移走的恰好是这个过期锁文件的进程可以重新抢锁
+		if !removeLockFile(lockPath, info) {
+			break
+		}
+	}
+	return nil, ErrTokenLocked
+}
+
+// removeLockFile 仅当 lockPath 仍是 info 对应的锁文件时删除
+// 先重命名为唯一的临时文件再核对，移走的是其他进程新建的锁时放回原处，避免两个进程同时持有锁
+func removeLockFile(lockPath string, info os.FileInfo) bool {
+	suffix, err := randomHex(8)
+	if err != nil {
+		return false
+	}
+	tmp := lockPath + "." + suffix
+	if err = os.Rename(lockPath, tmp); err != nil {
+		return false
+	}
+	defer os.Remove(tmp)
+	// 文件删除后 inode 可能被新锁文件复用，同时比较修改时间
+	moved, err := os.Stat(tmp)
+	if err == nil && os.SameFile(info, moved) && moved.ModTime().Equal(info.ModTime()) {
+		return true
+	}
+	os.Link(tmp, lockPath)
+	return false
+}
+
+// randomHex 生成 n 字节的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	value := make([]byte, n)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
+
+// RedisConfig Redis 连接配置，与 session.RedisConfig 为同一类型
+type RedisConfig = resp.Options
+
+// RedisTokenStore 基于 Redis 协议的 Token 存储，适用于跨机器的多副本部署，兼容 Redis、KeyDB、Valkey 等
+type RedisTokenStore struct {
+	client *resp.Client
+}
+
+// NewRedisTokenStore 创建 Redis Token 存储
+func NewRedisTokenStore(config RedisConfig) *RedisTokenStore {
+	return &RedisTokenStore{
+		client: resp.NewClient(config),
+	}
+}
+
+// Get 读取 Token
+func (s *RedisTokenStore) Get(ctx context.Context, key string) (string, time.Time, error) {
+	reply, err := s.client.Do(ctx, "GET", key)
+	if errors.Is(err, resp.ErrNil) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	data, _ := reply.(string)
+	token := storedToken{}
+	if err = json.Unmarshal([]byte(data), &token); err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, token.ExpireAt, nil
+}
+
+// Set 写入 Token，键随 Token 一起过期
+func (s *RedisTokenStore) Set(ctx context.Context, key, accessToken string, expireAt time.Time) error {
+	data, err := json.Marshal(storedToken{AccessToken: accessToken, ExpireAt: expireAt})
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(expireAt).Milliseconds()
+	if ttl <= 0 {
+		_, err = s.client.Do(ctx, "DEL", key)
+		return err
+	}
+	_, err = s.client.Do(ctx, "SET", key, data, "PX", ttl)
+	return err
+}
+
+// unlockScript 仅当锁仍由自己持有时删除，避免误删其他副本在锁过期后获得的锁
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// Lock 通过 SET NX PX 获取刷新锁
+func (s *RedisTokenStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	lockKey := key + ":lock"
+	owner, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.Do(ctx, "SET", lockKey, owner, "NX", "PX", ttl.Milliseconds())
+	if errors.Is(err, resp.ErrNil) {
+		return nil, ErrTokenLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		// 释放锁不跟随调用方 ctx，保证刷新完成后锁能被及时释放
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		s.client.Do(ctx, "EVAL", unlockScript, 1, lockKey, owner)
+	}, nil
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisTokenStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	url2 "net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidSign 签名不匹配
+	ErrInvalidSign = errors.New("dingtalk: invalid sign")
+	// ErrSignExpired 签名时间戳超出有效期
+	ErrSignExpired = errors.New("dingtalk: sign timestamp expired")
+)
+
+// SignValidity 钉钉签名时间戳的有效期
+const SignValidity = time.Hour
+
+// WebhookRobot 自定义机器人
+// 文档: https://open.dingtalk.com/document/robots/customize-robot-security-settings
+type WebhookRobot struct {
+	accessToken string
+	secret      string
+	client      *DingTalkClient
+}
+
+// NewWebhookRobot 创建自定义机器人，secret 为安全设置中的“加签”密钥，未启用加签时传空字符串
+// opts 用于配置 HTTP 客户端、超时与重试，与 DingTalkClient 相同
+func NewWebhookRobot(accessToken, secret string, opts ...Option) *WebhookRobot {
+	return &WebhookRobot{
+		accessToken: accessToken,
+		secret:      secret,
+		client:      NewDingTalkClient(Credential{}, opts...),
+	}
+}
+
+// NewWebhookRobotFromURL 通过完整的 Webhook 地址创建自定义机器人
+func NewWebhookRobotFromURL(webhookURL, secret string, opts ...Option) (*WebhookRobot, error) {
+	u, err := url2.Parse(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := u.Query().Get("access_token")
+	if accessToken == "" {
+		return nil, errors.New("dingtalk: webhook url has no access_token")
+	}
+	return NewWebhookRobot(accessToken, secret, opts...), nil
+}
+
+// Send 发送消息
+func (r *WebhookRobot) Send(message interface{}) error {
+	return r.SendWithContext(context.Background(), message)
+}
+
+// SendWithContext 发送消息，启用加签时每次请求（包括重试）都会重新计算 timestamp 和 sign
+func (r *WebhookRobot) SendWithContext(ctx context.Context, message interface{}) error {
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.callSend(ctx, false, defaultSendTimeout, func(ctx context.Context, _ string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", r.webhookURL(time.Now()), bytes.NewBuffer(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	return err
+}
+
+// webhookURL 生成带签名的 Webhook 地址
+func (r *WebhookRobot) webhookURL(now time.Time) string {
+	query := url2.Values{}
+	query.Set("access_token", r.accessToken)
+	if r.secret != "" {
+		timestamp := now.UnixMilli()
+		query.Set("timestamp", strconv.FormatInt(timestamp, 10))
+		query.Set("sign", Sign(timestamp, r.secret))
+	}
+	return r.client.oapiURL("/robot/send?" + query.Encode())
+}
+
+// Sign 计算钉钉签名：HmacSHA256(timestamp + "\n" + secret) 后 Base64 编码，timestamp 为毫秒时间戳
+// 自定义机器人加签与企业内部机器人回调验签使用同一算法
+func Sign(timestamp int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySign 校验钉钉签名，timestamp 为毫秒时间戳字符串，超过 SignValidity 的签名视为过期
+func VerifySign(timestamp, sign, secret string) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSign
+	}
+	skew := time.Since(time.UnixMilli(ts))
+	if skew > SignValidity || skew < -SignValidity {
+		return ErrSignExpired
+	}
+	if !hmac.Equal([]byte(Sign(ts, secret)), []byte(sign)) {
+		return ErrInvalidSign
+	}
+	return nil
+}
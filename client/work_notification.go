@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	url2 "net/url"
+	"strconv"
+	"strings"
+)
+
+// 工作通知接收人数量上限
+const (
+	MaxWorkNotificationUsers = 5000
+	MaxWorkNotificationDepts = 500
+)
+
+// 工作通知发送进度状态
+const (
+	SendProgressNotStarted = 0 // 未开始
+	SendProgressProcessing = 1 // 处理中
+	SendProgressFinished   = 2 // 处理完毕
+)
+
+// WorkNotificationRequest 工作通知发送请求
+type WorkNotificationRequest struct {
+	AgentID   int64       // 应用的 agentId
+	UserIDs   []string    // 接收人 userId，最多 MaxWorkNotificationUsers 个
+	DeptIDs   []int64     // 接收部门 ID，最多 MaxWorkNotificationDepts 个
+	ToAllUser bool        // 是否发送给企业全部用户
+	Msg       interface{} // 消息内容，例如 message.NewTextMessage、message.NewOAMessage 的返回值
+}
+
+// WorkNotificationProgress 工作通知发送进度
+type WorkNotificationProgress struct {
+	ProgressInPercent int64 `json:"progress_in_percent"`
+	Status            int64 `json:"status"` // 见 SendProgress* 常量
+}
+
+// WorkNotificationForbidden 因流控等原因被禁止发送的用户
+type WorkNotificationForbidden struct {
+	Code   string `json:"code"`
+	Count  int64  `json:"count"`
+	UserID string `json:"userid"`
+}
+
+// WorkNotificationResult 工作通知发送结果
+type WorkNotificationResult struct {
+	InvalidUserIDList   []string                    `json:"invalid_user_id_list"`
+	ForbiddenUserIDList []string                    `json:"forbidden_user_id_list"`
+	FailedUserIDList    []string                    `json:"failed_user_id_list"`
+	ReadUserIDList      []string                    `json:"read_user_id_list"`
+	UnreadUserIDList    []string                    `json:"unread_user_id_list"`
+	InvalidDeptIDList   []int64                     `json:"invalid_dept_id_list"`
+	ForbiddenList       []WorkNotificationForbidden `json:"forbidden_list"`
+}
+
+// SendWorkNotification 异步发送工作通知，返回用于查询进度、结果与撤回的 taskId
+func (c *DingTalkClient) SendWorkNotification(req *WorkNotificationRequest) (int64, error) {
+	return c.SendWorkNotificationWithContext(context.Background(), req)
+}
+
+// SendWorkNotificationWithContext 异步发送工作通知，返回用于查询进度、结果与撤回的 taskId，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/asynchronous-sending-of-enterprise-session-messages
+func (c *DingTalkClient) SendWorkNotificationWithContext(ctx context.Context, req *WorkNotificationRequest) (int64, error) {
+	if req.AgentID == 0 {
+		return 0, errors.New("dingtalk: agentId is required")
+	}
+	if !req.ToAllUser && len(req.UserIDs) == 0 && len(req.DeptIDs) == 0 {
+		return 0, errors.New("dingtalk: one of userIds, deptIds or toAllUser is required")
+	}
+	if len(req.UserIDs) > MaxWorkNotificationUsers {
+		return 0, fmt.Errorf("dingtalk: at most %d userIds, got %d", MaxWorkNotificationUsers, len(req.UserIDs))
+	}
+	if len(req.DeptIDs) > MaxWorkNotificationDepts {
+		return 0, fmt.Errorf("dingtalk: at most %d deptIds, got %d", MaxWorkNotificationDepts, len(req.DeptIDs))
+	}
+	if req.Msg == nil {
+		return 0, errors.New("dingtalk: msg is required")
+	}
+	if err := validateMessage(req.Msg); err != nil {
+		return 0, err
+	}
+
+	body := map[string]interface{}{
+		"agent_id": req.AgentID,
+		"msg":      req.Msg,
+	}
+	if len(req.UserIDs) > 0 {
+		body["userid_list"] = strings.Join(req.UserIDs, ",")
+	}
+	if len(req.DeptIDs) > 0 {
+		deptIDs := make([]string, len(req.DeptIDs))
+		for i, id := range req.DeptIDs {
+			deptIDs[i] = strconv.FormatInt(id, 10)
+		}
+		body["dept_id_list"] = strings.Join(deptIDs, ",")
+	}
+	if req.ToAllUser {
+		body["to_all_user"] = true
+	}
+
+	result := &struct {
+		TaskID int64 `json:"task_id"`
+	}{}
+	if err := c.sendOAPI(ctx, "/topapi/message/corpconversation/asyncsend_v2", body, result); err != nil {
+		return 0, err
+	}
+	return result.TaskID, nil
+}
+
+// GetWorkNotificationProgress 查询工作通知发送进度
+func (c *DingTalkClient) GetWorkNotificationProgress(agentID, taskID int64) (*WorkNotificationProgress, error) {
+	return c.GetWorkNotificationProgressWithContext(context.Background(), agentID, taskID)
+}
+
+// GetWorkNotificationProgressWithContext 查询工作通知发送进度，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/obtain-the-sending-progress-of-asynchronous-sending-of-enterprise
+func (c *DingTalkClient) GetWorkNotificationProgressWithContext(ctx context.Context, agentID, taskID int64) (*WorkNotificationProgress, error) {
+	result := &struct {
+		Progress WorkNotificationProgress `json:"progress"`
+	}{}
+	body := map[string]interface{}{"agent_id": agentID, "task_id": taskID}
+	if err := c.doOAPI(ctx, "/topapi/message/corpconversation/getsendprogress", body, result); err != nil {
+		return nil, err
+	}
+	return &result.Progress, nil
+}
+
+// GetWorkNotificationResult 查询工作通知发送结果
+func (c *DingTalkClient) GetWorkNotificationResult(agentID, taskID int64) (*WorkNotificationResult, error) {
+	return c.GetWorkNotificationResultWithContext(context.Background(), agentID, taskID)
+}
+
+// GetWorkNotificationResultWithContext 查询工作通知发送结果，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/gets-the-result-of-sending-messages-asynchronously-to-the-enterprise
+func (c *DingTalkClient) GetWorkNotificationResultWithContext(ctx context.Context, agentID, taskID int64) (*WorkNotificationResult, error) {
+	result := &struct {
+		SendResult WorkNotificationResult `json:"send_result"`
+	}{}
+	body := map[string]interface{}{"agent_id": agentID, "task_id": taskID}
+	if err := c.doOAPI(ctx, "/topapi/message/corpconversation/getsendresult", body, result); err != nil {
+		return nil, err
+	}
+	return &result.SendResult, nil
+}
+
+// RecallWorkNotification 撤回工作通知
+func (c *DingTalkClient) RecallWorkNotification(agentID, taskID int64) error {
+	return c.RecallWorkNotificationWithContext(context.Background(), agentID, taskID)
+}
+
+// RecallWorkNotificationWithContext 撤回工作通知，ctx 取消时中断请求
+// 文档: https://open.dingtalk.com/document/orgapp/notification-of-work-withdrawal
+func (c *DingTalkClient) RecallWorkNotificationWithContext(ctx context.Context, agentID, taskID int64) error {
+	body := map[string]interface{}{"agent_id": agentID, "msg_task_id": taskID}
+	return c.doOAPI(ctx, "/topapi/message/corpconversation/recall", body, nil)
+}
+
+// doOAPI 以 POST JSON 方式调用旧版 OpenAPI，access_token 通过查询参数传递，result 为 nil 时忽略响应体
+func (c *DingTalkClient) doOAPI(ctx context.Context, path string, body, result interface{}) error {
+	return c.oapi(ctx, c.call, path, body, result)
+}
+
+// sendOAPI 调用旧版 OpenAPI 的发送接口，无法确认是否送达的网络错误不重试
+func (c *DingTalkClient) sendOAPI(ctx context.Context, path string, body, result interface{}) error {
+	return c.oapi(ctx, c.callSend, path, body, result)
+}
+
+func (c *DingTalkClient) oapi(ctx context.Context, call callFunc, path string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	respBody, err := call(ctx, true, defaultSendTimeout, func(ctx context.Context, accessToken string) (*http.Request, error) {
+		url := c.oapiURL(fmt.Sprintf("%s?access_token=%s", path, url2.QueryEscape(accessToken)))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
@@ -20,14 +20,8 @@ func main() {
 
 	dingClient := client.NewDingTalkClient(credential)
 
-	// 获取 Access Token
-	accessToken, err := dingClient.GetAccessToken()
-	if err != nil {
-		log.Fatalf("Failed to get access token: %v", err)
-	}
-
-	// 创建流式卡片客户端
-	streamClient, err := stream.NewStreamCardClient()
+	// 创建流式卡片客户端，AccessToken 由 dingClient 获取并自动刷新
+	streamClient, err := stream.NewStreamCardClientWithTokenProvider(dingClient)
 	if err != nil {
 		log.Fatalf("Failed to create stream card client: %v", err)
 	}
@@ -48,7 +42,7 @@ func main() {
 		},
 	}
 
-	err = streamClient.CreateAndDeliverCard(accessToken, cardReq)
+	err = streamClient.CreateAndDeliverCard("", cardReq)
 	if err != nil {
 		log.Fatalf("Failed to create and deliver card: %v", err)
 	}
@@ -76,7 +70,7 @@ func main() {
 			IsFinalize: isFinalize,
 		}
 
-		err = streamClient.StreamingUpdate(accessToken, updateReq)
+		err = streamClient.StreamingUpdate("", updateReq)
 		if err != nil {
 			log.Printf("Failed to update stream card: %v", err)
 			continue
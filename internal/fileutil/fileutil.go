@@ -0,0 +1,41 @@
+// Package fileutil 提供 Token 与会话文件存储共用的文件名编码与原子写入
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// maxNameLength 编码后文件名的长度上限，多数文件系统限制单个文件名不超过 255 字节
+const maxNameLength = 200
+
+// Path 返回键在 dir 下对应的文件路径，ext 为扩展名（例如 ".json"）
+// 键按 base64url 编码，不同的键不会映射到同一个文件；编码过长时改用键的 SHA-256
+func Path(dir, key, ext string) string {
+	name := base64.RawURLEncoding.EncodeToString([]byte(key))
+	if len(name) > maxNameLength {
+		sum := sha256.Sum256([]byte(key))
+		name = "sha256-" + hex.EncodeToString(sum[:])
+	}
+	return filepath.Join(dir, name+ext)
+}
+
+// WriteFile 先写同目录下的临时文件再重命名，避免其他进程读到写了一半的内容
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
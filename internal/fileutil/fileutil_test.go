@@ -0,0 +1,41 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	// senderId 中同时含有 ":" 与 "_"，替换字符的编码会让这两个键落到同一个文件
+	a := Path(dir, "session:cid:$:LWCP_v1:$abc", ".json")
+	b := Path(dir, "session:cid:$_LWCP_v1_$abc", ".json")
+	if a == b {
+		t.Errorf("Expected distinct paths, got %s", a)
+	}
+	if filepath.Dir(a) != dir || !strings.HasSuffix(a, ".json") || strings.ContainsAny(filepath.Base(a), `/\:`) {
+		t.Errorf("Unexpected path %s", a)
+	}
+	long := Path(dir, strings.Repeat("k", 1000), ".json")
+	if len(filepath.Base(long)) > 255 {
+		t.Errorf("Expected long key to be hashed, got %d bytes", len(filepath.Base(long)))
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := Path(t.TempDir(), "key", ".json")
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != content {
+			t.Errorf("Expected %q, got %q (%v)", content, data, err)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected temp files to be removed, got %d entries", len(entries))
+	}
+}
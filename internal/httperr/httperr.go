@@ -0,0 +1,17 @@
+// Package httperr 统一 HTTP 回调处理器的错误响应：错误详情只写入日志，响应体中不包含内部信息
+package httperr
+
+import (
+	"log"
+	"net/http"
+)
+
+// Internal 将 err 写入 logger（为 nil 时使用 log.Default()）并返回 500，响应体只有通用的错误提示
+// prefix 标识出错的处理器，例如 "callback"
+func Internal(w http.ResponseWriter, logger *log.Logger, prefix string, err error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("%s: handler error: %v", prefix, err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
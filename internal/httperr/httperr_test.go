@@ -0,0 +1,24 @@
+package httperr
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInternal(t *testing.T) {
+	var logs bytes.Buffer
+	rec := httptest.NewRecorder()
+	Internal(rec, log.New(&logs, "", 0), "test", errors.New("db password wrong"))
+
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("Expected generic 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := logs.String(); got != "test: handler error: db password wrong\n" {
+		t.Errorf("Unexpected log %q", got)
+	}
+}
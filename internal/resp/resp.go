@@ -0,0 +1,248 @@
+// Package resp 实现 Redis 协议（RESP2）的最小客户端，供 Token 与会话存储使用，避免引入第三方依赖
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrNil 键不存在时 Redis 返回的空值
+var ErrNil = errors.New("resp: nil reply")
+
+// Error Redis 返回的错误回复，例如 WRONGTYPE
+type Error string
+
+// Error 实现 error 接口
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Options 连接配置，对外通过 client.RedisConfig 与 session.RedisConfig 使用
+type Options struct {
+	Addr        string        // 地址，例如 127.0.0.1:6379
+	Username    string        // ACL 用户名，可选
+	Password    string        // 密码，可选
+	DB          int           // 数据库编号
+	DialTimeout time.Duration // 连接超时，默认 5s
+	ReadTimeout time.Duration // 未设置 ctx 截止时间时单条命令的读写超时，默认 5s
+	PoolSize    int           // 空闲连接数，默认 4
+}
+
+// Client Redis 客户端，内部维护一个空闲连接池，可并发使用
+type Client struct {
+	opts Options
+	idle chan *conn
+}
+
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+}
+
+// NewClient 创建客户端，连接在首次执行命令时建立
+func NewClient(opts Options) *Client {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = time.Second * 5
+	}
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = time.Second * 5
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 4
+	}
+	return &Client{
+		opts: opts,
+		idle: make(chan *conn, opts.PoolSize),
+	}
+}
+
+// Do 执行一条命令，返回值为 string、int64、[]interface{} 之一，键不存在时返回 ErrNil
+// 空闲连接已被服务端关闭（例如超过 Redis 的 timeout 配置）时丢弃该连接并换一个连接重新执行
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	for {
+		cn, pooled, err := c.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cn.netConn.SetDeadline(c.deadline(ctx))
+
+		reply, err := cn.do(args...)
+		var respErr Error
+		if err != nil && err != ErrNil && !errors.As(err, &respErr) {
+			// 网络错误时连接状态未知，直接丢弃
+			cn.netConn.Close()
+			if pooled && isClosedByPeer(err) {
+				continue
+			}
+			return nil, err
+		}
+		c.put(cn)
+		return reply, err
+	}
+}
+
+// isClosedByPeer 判断错误是否由对端已关闭连接引起，此时命令未被执行，可以安全地重新执行
+// 超时等无法确认命令是否已执行的错误不在此列
+func isClosedByPeer(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// Close 关闭所有空闲连接
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.netConn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// deadline 命令的截止时间，ctx 未设置时使用 ReadTimeout，避免服务端无响应时永远阻塞
+func (c *Client) deadline(ctx context.Context) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(c.opts.ReadTimeout)
+}
+
+// get 取出一个空闲连接或新建连接，pooled 表示连接来自空闲连接池
+func (c *Client) get(ctx context.Context) (cn *conn, pooled bool, err error) {
+	select {
+	case cn = <-c.idle:
+		return cn, true, nil
+	default:
+	}
+	cn, err = c.dial(ctx)
+	return cn, false, err
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{netConn: netConn, reader: bufio.NewReader(netConn)}
+	netConn.SetDeadline(c.deadline(ctx))
+	if c.opts.Password != "" {
+		args := []interface{}{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			args = []interface{}{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if _, err = cn.do(args...); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err = cn.do("SELECT", c.opts.DB); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+func (cn *conn) do(args ...interface{}) (interface{}, error) {
+	if _, err := cn.netConn.Write(encode(args)); err != nil {
+		return nil, err
+	}
+	return readReply(cn.reader)
+}
+
+// encode 将命令编码为 RESP 数组
+func encode(args []interface{}) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		default:
+			s = fmt.Sprint(v)
+		}
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(s)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, s...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readReply 读取一条 RESP 回复
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("resp: malformed reply %q", line)
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, Error(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, ErrNil
+		}
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i], err = readReply(r)
+			if err != nil && err != ErrNil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply type %q", line[0])
+}
@@ -0,0 +1,97 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientDo(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected listener, got %v", err)
+	}
+	defer listener.Close()
+
+	replies := []string{
+		"+OK\r\n",
+		"$5\r\nvalue\r\n",
+		"$-1\r\n",
+		":1\r\n",
+		"-ERR unknown command\r\n",
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for _, reply := range replies {
+			// 命令本身也是 RESP 数组，可直接用 readReply 读取
+			if _, err := readReply(reader); err != nil {
+				return
+			}
+			conn.Write([]byte(reply))
+		}
+	}()
+
+	client := NewClient(Options{Addr: listener.Addr().String(), PoolSize: 1})
+	defer client.Close()
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "SET", "key", "value"); err != nil || reply != "OK" {
+		t.Errorf("Expected OK, got %v (%v)", reply, err)
+	}
+	if reply, err := client.Do(ctx, "GET", "key"); err != nil || reply != "value" {
+		t.Errorf("Expected value, got %v (%v)", reply, err)
+	}
+	if _, err := client.Do(ctx, "GET", "missing"); !errors.Is(err, ErrNil) {
+		t.Errorf("Expected ErrNil, got %v", err)
+	}
+	if reply, err := client.Do(ctx, "DEL", "key"); err != nil || reply != int64(1) {
+		t.Errorf("Expected 1, got %v (%v)", reply, err)
+	}
+	var respErr Error
+	if _, err := client.Do(ctx, "UNKNOWN"); !errors.As(err, &respErr) {
+		t.Errorf("Expected Error reply, got %v", err)
+	}
+}
+
+func TestClientDoStaleConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected listener, got %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		// 第一个连接处理一条命令后关闭，模拟服务端回收空闲连接
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			if _, err := readReply(reader); err == nil {
+				conn.Write([]byte("+PONG\r\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	client := NewClient(Options{Addr: listener.Addr().String(), PoolSize: 1})
+	defer client.Close()
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "PING"); err != nil || reply != "PONG" {
+		t.Fatalf("Expected PONG, got %v (%v)", reply, err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if reply, err := client.Do(ctx, "PING"); err != nil || reply != "PONG" {
+		t.Errorf("Expected stale connection to be replaced, got %v (%v)", reply, err)
+	}
+}
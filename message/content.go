@@ -0,0 +1,134 @@
+package message
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// 机器人接收的消息类型
+// 文档: https://open.dingtalk.com/document/orgapp/receive-message
+const (
+	PICTURE         MsgType = "picture"
+	RICHTEXT        MsgType = "richText"
+	AUDIO           MsgType = "audio"
+	VIDEO           MsgType = "video"
+	FILE            MsgType = "file"
+	INTERACTIVECARD MsgType = "interactiveCard"
+)
+
+// Content 非文本消息的内容，不同 msgtype 使用其中不同的字段
+// picture: DownloadCode；richText: RichText；audio: DownloadCode、Duration、Recognition；
+// video: DownloadCode、Duration、VideoType；file: DownloadCode、FileName、FileID、SpaceID；
+// interactiveCard 等其他类型可通过 Decode 解析 Raw
+type Content struct {
+	DownloadCode        string            `json:"downloadCode,omitempty"`
+	PictureDownloadCode string            `json:"pictureDownloadCode,omitempty"`
+	RichText            []RichTextSection `json:"richText,omitempty"`
+	Recognition         string            `json:"recognition,omitempty"` // 语音识别结果
+	Duration            Number            `json:"duration,omitempty"`    // 音频、视频时长
+	VideoType           string            `json:"videoType,omitempty"`
+	FileName            string            `json:"fileName,omitempty"`
+	FileID              string            `json:"fileId,omitempty"`
+	SpaceID             string            `json:"spaceId,omitempty"`
+	Raw                 json.RawMessage   `json:"-"` // 原始 content JSON
+}
+
+// RichTextSection 富文本消息中的一段，文本段只有 Text，图片段 Type 为 picture
+type RichTextSection struct {
+	Text                string  `json:"text,omitempty"`
+	Type                MsgType `json:"type,omitempty"`
+	DownloadCode        string  `json:"downloadCode,omitempty"`
+	PictureDownloadCode string  `json:"pictureDownloadCode,omitempty"`
+}
+
+// Number 兼容钉钉以数字或字符串返回的整数字段
+type Number int64
+
+// UnmarshalJSON 同时接受 123 与 "123"
+func (n *Number) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = Number(v)
+	return nil
+}
+
+// UnmarshalJSON 保留原始 content 以便解析未建模的消息类型
+func (c *Content) UnmarshalJSON(data []byte) error {
+	type alias Content
+	a := alias{}
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+	*c = Content(a)
+	c.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Decode 将原始 content 解析为自定义结构，用于 interactiveCard 等消息
+func (c *Content) Decode(v interface{}) error {
+	return json.Unmarshal(c.Raw, v)
+}
+
+// MediaPart 消息中的一个媒体文件，DownloadCode 可用于换取下载链接
+type MediaPart struct {
+	Type         MsgType
+	DownloadCode string
+	FileName     string // 仅文件消息
+}
+
+// TextParts 返回消息中的全部文本：文本消息的内容、富文本中的文本段、语音识别结果
+func (r ReceiveMsg) TextParts() []string {
+	var parts []string
+	if r.Text.Content != "" {
+		parts = append(parts, r.Text.Content)
+	}
+	if r.Content == nil {
+		return parts
+	}
+	for _, section := range r.Content.RichText {
+		if section.Text != "" {
+			parts = append(parts, section.Text)
+		}
+	}
+	if r.Content.Recognition != "" {
+		parts = append(parts, r.Content.Recognition)
+	}
+	return parts
+}
+
+// PlainText 拼接 TextParts 并去除首尾空白
+func (r ReceiveMsg) PlainText() string {
+	return strings.TrimSpace(strings.Join(r.TextParts(), "\n"))
+}
+
+// MediaParts 返回消息中的全部媒体：图片、语音、视频、文件以及富文本中的图片
+func (r ReceiveMsg) MediaParts() []MediaPart {
+	if r.Content == nil {
+		return nil
+	}
+	var parts []MediaPart
+	switch r.Msgtype {
+	case PICTURE, AUDIO, VIDEO:
+		if r.Content.DownloadCode != "" {
+			parts = append(parts, MediaPart{Type: r.Msgtype, DownloadCode: r.Content.DownloadCode})
+		}
+	case FILE:
+		if r.Content.DownloadCode != "" {
+			parts = append(parts, MediaPart{Type: FILE, DownloadCode: r.Content.DownloadCode, FileName: r.Content.FileName})
+		}
+	}
+	for _, section := range r.Content.RichText {
+		if section.DownloadCode != "" {
+			parts = append(parts, MediaPart{Type: PICTURE, DownloadCode: section.DownloadCode})
+		}
+	}
+	return parts
+}
@@ -0,0 +1,78 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
+	"github.com/difyz9/dingtalk-sdk.git/internal/httperr"
+)
+
+// maxReceiveBodySize 机器人回调请求体大小上限
+const maxReceiveBodySize = 1 << 20
+
+// HandlerFunc 处理机器人收到的消息，返回的消息作为 HTTP 同步响应回复到会话，返回 nil 表示不回复
+type HandlerFunc func(ctx context.Context, msg *ReceiveMsg) (Message, error)
+
+// Handler 机器人消息接收地址（outgoing）的 http.Handler
+// 校验请求头中的 timestamp/sign，超过 client.SignValidity 的请求视为过期并拒绝
+// 文档: https://open.dingtalk.com/document/orgapp/receive-message
+type Handler struct {
+	appSecret string
+	handle    HandlerFunc
+
+	// ErrorLog 记录消息处理失败的原因，为 nil 时使用 log.Default()
+	ErrorLog *log.Logger
+}
+
+// NewHandler 创建机器人消息接收处理器，appSecret 为机器人所属应用的 AppSecret
+// appSecret 为空时任何人都能算出签名，此时所有请求都以 401 拒绝
+func NewHandler(appSecret string, handle HandlerFunc) *Handler {
+	return &Handler{appSecret: appSecret, handle: handle}
+}
+
+// ServeHTTP 校验签名、解析 ReceiveMsg 并写回处理函数返回的消息
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.appSecret == "" {
+		http.Error(w, "app secret not configured", http.StatusUnauthorized)
+		return
+	}
+	if err := client.VerifySign(r.Header.Get("timestamp"), r.Header.Get("sign"), h.appSecret); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	msg := &ReceiveMsg{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxReceiveBodySize)).Decode(msg); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	reply, err := h.handle(r.Context(), msg)
+	if err != nil {
+		httperr.Internal(w, h.ErrorLog, "message", err)
+		return
+	}
+	if reply == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err = reply.Validate(); err != nil {
+		httperr.Internal(w, h.ErrorLog, "message", err)
+		return
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		httperr.Internal(w, h.ErrorLog, "message", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
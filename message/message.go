@@ -2,9 +2,13 @@ package message
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
 )
 
 // MsgType 消息类型
@@ -21,21 +25,22 @@ type ReceiveMsg struct {
 	AtUsers        []struct {
 		DingtalkID string `json:"dingtalkId"`
 	} `json:"atUsers"`
-	ChatbotUserID             string  `json:"chatbotUserId"`
-	MsgID                     string  `json:"msgId"`
-	SenderNick                string  `json:"senderNick"`
-	IsAdmin                   bool    `json:"isAdmin"`
-	SenderStaffId             string  `json:"senderStaffId"`
-	SessionWebhookExpiredTime int64   `json:"sessionWebhookExpiredTime"`
-	CreateAt                  int64   `json:"createAt"`
-	ConversationType          string  `json:"conversationType"`
-	SenderID                  string  `json:"senderId"`
-	ConversationTitle         string  `json:"conversationTitle"`
-	IsInAtList                bool    `json:"isInAtList"`
-	SessionWebhook            string  `json:"sessionWebhook"`
-	Text                      Text    `json:"text"`
-	RobotCode                 string  `json:"robotCode"`
-	Msgtype                   MsgType `json:"msgtype"`
+	ChatbotUserID             string   `json:"chatbotUserId"`
+	MsgID                     string   `json:"msgId"`
+	SenderNick                string   `json:"senderNick"`
+	IsAdmin                   bool     `json:"isAdmin"`
+	SenderStaffId             string   `json:"senderStaffId"`
+	SessionWebhookExpiredTime int64    `json:"sessionWebhookExpiredTime"`
+	CreateAt                  int64    `json:"createAt"`
+	ConversationType          string   `json:"conversationType"`
+	SenderID                  string   `json:"senderId"`
+	ConversationTitle         string   `json:"conversationTitle"`
+	IsInAtList                bool     `json:"isInAtList"`
+	SessionWebhook            string   `json:"sessionWebhook"`
+	Text                      Text     `json:"text"`
+	Content                   *Content `json:"content,omitempty"` // 图片、富文本、语音、视频、文件等消息的内容
+	RobotCode                 string   `json:"robotCode"`
+	Msgtype                   MsgType  `json:"msgtype"`
 }
 
 // TextMessage 文本消息
@@ -82,7 +87,7 @@ func (r ReceiveMsg) GetSenderIdentifier() (uid string) {
 
 // GetChatTitle 获取聊天的群名字，如果是私聊，则命名为 昵称_私聊
 func (r ReceiveMsg) GetChatTitle() (chatType string) {
-	if r.ConversationType == "1" {
+	if r.ConversationType == ConversationTypeSingle {
 		chatType = r.SenderNick + "_私聊"
 	} else {
 		chatType = r.ConversationTitle
@@ -92,16 +97,22 @@ func (r ReceiveMsg) GetChatTitle() (chatType string) {
 
 // ReplyToDingtalk 发消息给钉钉
 func (r ReceiveMsg) ReplyToDingtalk(msgType, msg string) (statuscode int, err error) {
+	return r.ReplyToDingtalkWithContext(context.Background(), msgType, msg)
+}
+
+// ReplyToDingtalkWithContext 发消息给钉钉，ctx 取消时中断请求
+// 钉钉返回错误时 err 为 *client.APIError
+func (r ReceiveMsg) ReplyToDingtalkWithContext(ctx context.Context, msgType, msg string) (statuscode int, err error) {
 	atUser := r.SenderStaffId
 	if atUser == "" {
 		msg = fmt.Sprintf("%s\n\n@%s", msg, r.SenderNick)
 	}
-	var msgtmp interface{}
+	var msgtmp Message
 	switch msgType {
 	case string(TEXT):
 		msgtmp = &TextMessage{Text: &Text{Content: msg}, MsgType: TEXT, At: &At{AtUserIds: []string{atUser}}}
 	case string(MARKDOWN):
-		if atUser != "" && r.ConversationType != "1" {
+		if atUser != "" && r.ConversationType != ConversationTypeSingle {
 			msg = fmt.Sprintf("%s\n\n@%s", msg, atUser)
 		}
 		msgtmp = &MarkDownMessage{MsgType: MARKDOWN, At: &At{AtUserIds: []string{atUser}}, MarkDown: &MarkDown{Title: "Markdown Msg", Text: msg}}
@@ -109,22 +120,41 @@ func (r ReceiveMsg) ReplyToDingtalk(msgType, msg string) (statuscode int, err er
 		msgtmp = &TextMessage{Text: &Text{Content: msg}, MsgType: TEXT, At: &At{AtUserIds: []string{atUser}}}
 	}
 
-	data, err := json.Marshal(msgtmp)
+	return r.ReplyMessageWithContext(ctx, msgtmp)
+}
+
+// ReplyMessage 通过 SessionWebhook 回复任意类型的消息
+func (r ReceiveMsg) ReplyMessage(msg Message) (statuscode int, err error) {
+	return r.ReplyMessageWithContext(context.Background(), msg)
+}
+
+// ReplyMessageWithContext 通过 SessionWebhook 回复任意类型的消息，发送前校验消息
+// 钉钉返回错误时 err 为 *client.APIError
+func (r ReceiveMsg) ReplyMessageWithContext(ctx context.Context, msg Message) (statuscode int, err error) {
+	if err = msg.Validate(); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return 0, err
 	}
 
-	req, err := http.NewRequest("POST", r.SessionWebhook, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", r.SessionWebhook, bytes.NewBuffer(data))
 	if err != nil {
 		return 0, err
 	}
 	req.Header.Add("Accept", "*/*")
 	req.Header.Add("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
-	return resp.StatusCode, nil
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	// 钉钉在 HTTP 200 的响应体中通过 errcode 返回业务错误
+	return resp.StatusCode, client.CheckResponse(resp, body)
 }
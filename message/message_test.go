@@ -1,7 +1,20 @@
 package message
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
 )
 
 func TestGetSenderIdentifier(t *testing.T) {
@@ -73,3 +86,400 @@ func TestGetChatTitle(t *testing.T) {
 		})
 	}
 }
+
+func TestReplyToDingtalkWithContextTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	msg := ReceiveMsg{SessionWebhook: server.URL, SenderStaffId: "staff123"}
+	_, err := msg.ReplyToDingtalkWithContext(ctx, string(TEXT), "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr bool
+	}{
+		{name: "Text", msg: NewTextMessage("hello").AtUsers("user1")},
+		{name: "Empty text", msg: NewTextMessage(""), wantErr: true},
+		{name: "Markdown", msg: NewMarkdownMessage("标题", "### 内容")},
+		{name: "Markdown without title", msg: NewMarkdownMessage("", "### 内容"), wantErr: true},
+		{name: "Title too long", msg: NewMarkdownMessage(strings.Repeat("长", MaxTitleLength+1), "内容"), wantErr: true},
+		{name: "Link", msg: NewLinkMessage("标题", "内容", "https://www.dingtalk.com", "")},
+		{name: "Link without url", msg: NewLinkMessage("标题", "内容", "", ""), wantErr: true},
+		{name: "Single action card", msg: NewSingleActionCard("标题", "内容", "查看详情", "https://www.dingtalk.com")},
+		{name: "Multi action card", msg: NewActionCard("标题", "内容").AddButton("同意", "https://a").AddButton("拒绝", "https://b").Horizontal()},
+		{name: "Action card without buttons", msg: NewActionCard("标题", "内容"), wantErr: true},
+		{name: "Feed card", msg: NewFeedCardMessage().AddLink("标题", "https://a", "https://a.png")},
+		{name: "Empty feed card", msg: NewFeedCardMessage(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMessageMarshal(t *testing.T) {
+	msg := &ActionCardMessage{ActionCard: &ActionCard{Title: "标题", Text: "内容", SingleTitle: "查看", SingleURL: "https://a"}}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"msgtype":"actionCard","actionCard":{"title":"标题","text":"内容","singleTitle":"查看","singleURL":"https://a"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+func TestSendRobotMessageChatFormat(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gettoken" {
+			w.Write([]byte(`{"errcode":0,"access_token":"token123","expires_in":7200}`))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer server.Close()
+	c := client.NewDingTalkClient(client.Credential{}, client.WithOAPIBaseURL(server.URL))
+
+	msg := NewActionCard("标题", "内容").AddButton("同意", "https://a").Horizontal()
+	if err := c.SendRobotMessage("chat123", msg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"chatId":"chat123","msg":{"action_card":{"title":"标题","markdown":"内容","btn_orientation":"1","btn_json_list":[{"title":"同意","action_url":"https://a"}]},"msgtype":"action_card"}}`
+	if body != expected {
+		t.Errorf("Expected %s, got %s", expected, body)
+	}
+
+	body = ""
+	err := c.SendRobotMessage("chat123", NewFeedCardMessage().AddLink("标题", "https://a", "https://a.png"))
+	if !errors.Is(err, client.ErrUnsupportedMessage) {
+		t.Errorf("Expected ErrUnsupportedMessage, got %v", err)
+	}
+	if body != "" {
+		t.Errorf("Expected no request, got %s", body)
+	}
+}
+
+func TestOAMessage(t *testing.T) {
+	msg := NewOAMessage("https://www.dingtalk.com", "审批通知", "FFBBBBBB").AddForm("申请人", "张三")
+	if err := msg.Validate(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"msgtype":"oa","oa":{"message_url":"https://www.dingtalk.com","head":{"bgcolor":"FFBBBBBB","text":"审批通知"},"body":{"form":[{"key":"申请人","value":"张三"}]}}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	const secret = "SEC123"
+	handler := NewHandler(secret, func(ctx context.Context, msg *ReceiveMsg) (Message, error) {
+		if msg.Text.Content == "silent" {
+			return nil, nil
+		}
+		if msg.Text.Content == "fail" {
+			return nil, errors.New("database password is wrong")
+		}
+		return NewTextMessage("echo: " + msg.Text.Content).AtUsers(msg.SenderStaffId), nil
+	})
+
+	post := func(timestamp time.Time, sign, content string) *httptest.ResponseRecorder {
+		ts := timestamp.UnixMilli()
+		if sign == "" {
+			sign = client.Sign(ts, secret)
+		}
+		body := `{"msgtype":"text","senderStaffId":"user1","text":{"content":"` + content + `"}}`
+		req := httptest.NewRequest("POST", "/robot", strings.NewReader(body))
+		req.Header.Set("timestamp", strconv.FormatInt(ts, 10))
+		req.Header.Set("sign", sign)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(time.Now(), "", "hello")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	reply := &TextMessage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text.Content != "echo: hello" || reply.At.AtUserIds[0] != "user1" {
+		t.Errorf("Unexpected reply: %s", rec.Body.String())
+	}
+
+	if rec = post(time.Now(), "", "silent"); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expected empty 200 response, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var logs bytes.Buffer
+	handler.ErrorLog = log.New(&logs, "", 0)
+	rec = post(time.Now(), "", "fail")
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("Expected generic 500 response, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "database password is wrong") {
+		t.Errorf("Expected handler error to be logged, got %q", logs.String())
+	}
+	if rec = post(time.Now(), "invalid", "hello"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for invalid sign, got %d", rec.Code)
+	}
+	if rec = post(time.Now().Add(-2*time.Hour), "", "hello"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for stale request, got %d", rec.Code)
+	}
+
+	// 未配置 AppSecret 时，即使签名按空密钥计算也拒绝
+	handler = NewHandler("", handler.handle)
+	req := httptest.NewRequest("POST", "/robot", strings.NewReader(`{"msgtype":"text"}`))
+	ts := time.Now().UnixMilli()
+	req.Header.Set("timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("sign", client.Sign(ts, ""))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without app secret, got %d", rec.Code)
+	}
+}
+
+func TestReceiveMsgContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		text  string
+		media []MediaPart
+	}{
+		{
+			name:  "picture",
+			body:  `{"msgtype":"picture","content":{"downloadCode":"pic1","pictureDownloadCode":"p"}}`,
+			media: []MediaPart{{Type: PICTURE, DownloadCode: "pic1"}},
+		},
+		{
+			name:  "rich text",
+			body:  `{"msgtype":"richText","content":{"richText":[{"text":"look"},{"type":"picture","downloadCode":"pic2"},{"text":"here"}]}}`,
+			text:  "look\nhere",
+			media: []MediaPart{{Type: PICTURE, DownloadCode: "pic2"}},
+		},
+		{
+			name:  "audio",
+			body:  `{"msgtype":"audio","content":{"duration":4000,"downloadCode":"voice1","recognition":"你好"}}`,
+			text:  "你好",
+			media: []MediaPart{{Type: AUDIO, DownloadCode: "voice1"}},
+		},
+		{
+			name:  "video",
+			body:  `{"msgtype":"video","content":{"duration":"3","downloadCode":"video1","videoType":"mp4"}}`,
+			media: []MediaPart{{Type: VIDEO, DownloadCode: "video1"}},
+		},
+		{
+			name:  "file",
+			body:  `{"msgtype":"file","content":{"downloadCode":"file1","fileName":"a.pdf","fileId":"f","spaceId":"s"}}`,
+			media: []MediaPart{{Type: FILE, DownloadCode: "file1", FileName: "a.pdf"}},
+		},
+		{
+			name: "text",
+			body: `{"msgtype":"text","text":{"content":" hello "}}`,
+			text: "hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := ReceiveMsg{}
+			if err := json.Unmarshal([]byte(tt.body), &msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := msg.PlainText(); got != tt.text {
+				t.Errorf("Expected text %q, got %q", tt.text, got)
+			}
+			media := msg.MediaParts()
+			if len(media) != len(tt.media) {
+				t.Fatalf("Expected %d media parts, got %+v", len(tt.media), media)
+			}
+			for i := range media {
+				if media[i] != tt.media[i] {
+					t.Errorf("Expected media %+v, got %+v", tt.media[i], media[i])
+				}
+			}
+		})
+	}
+
+	msg := ReceiveMsg{}
+	json.Unmarshal([]byte(`{"msgtype":"video","content":{"duration":"3","videoType":"mp4"}}`), &msg)
+	if msg.Content.Duration != 3 || msg.Content.VideoType != "mp4" {
+		t.Errorf("Unexpected video content: %+v", msg.Content)
+	}
+	card := struct {
+		CardTemplateID string `json:"cardTemplateId"`
+	}{}
+	json.Unmarshal([]byte(`{"msgtype":"interactiveCard","content":{"cardTemplateId":"tpl1"}}`), &msg)
+	if err := msg.Content.Decode(&card); err != nil || card.CardTemplateID != "tpl1" {
+		t.Errorf("Expected decoded card template, got %+v, %v", card, err)
+	}
+}
+
+func TestReplier(t *testing.T) {
+	var webhookCode, webhookStatus int
+	var sent map[string]interface{}
+	var sentPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webhook":
+			if webhookStatus != 0 {
+				w.WriteHeader(webhookStatus)
+				return
+			}
+			if webhookCode != 0 {
+				w.Write([]byte(`{"errcode":` + strconv.Itoa(webhookCode) + `,"errmsg":"session webhook invalid"}`))
+				return
+			}
+			w.Write([]byte(`{"errcode":0}`))
+		case "/gettoken":
+			w.Write([]byte(`{"errcode":0,"access_token":"token123","expires_in":7200}`))
+		case "/v1.0/robot/groupMessages/send", "/v1.0/robot/oToMessages/batchSend":
+			sentPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.Write([]byte(`{"processQueryKey":"key1"}`))
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	dingClient := client.NewDingTalkClient(client.Credential{ClientID: "id", ClientSecret: "secret"},
+		client.WithOAPIBaseURL(server.URL), client.WithOpenAPIBaseURL(server.URL), client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 1}))
+	replier := NewReplier(dingClient)
+	ctx := context.Background()
+	msg := &ReceiveMsg{
+		ConversationID:            "cid1",
+		ConversationType:          ConversationTypeGroup,
+		RobotCode:                 "robot1",
+		SenderStaffId:             "user1",
+		SessionWebhook:            server.URL + "/webhook",
+		SessionWebhookExpiredTime: time.Now().Add(time.Hour).UnixMilli(),
+	}
+
+	result, err := replier.Reply(ctx, msg, NewTextMessage("hello"))
+	if err != nil || result.Fallback {
+		t.Fatalf("Expected webhook reply, got %+v, %v", result, err)
+	}
+
+	// Webhook 返回 errcode 时降级为群聊发送
+	webhookCode = 300001
+	result, err = replier.Reply(ctx, msg, NewMarkdownMessage("标题", "内容"))
+	if err != nil || !result.Fallback || result.ProcessQueryKey != "key1" {
+		t.Fatalf("Expected fallback, got %+v, %v", result, err)
+	}
+	if sentPath != "/v1.0/robot/groupMessages/send" || sent["openConversationId"] != "cid1" || sent["msgKey"] != client.MsgKeySampleMarkdown {
+		t.Errorf("Unexpected fallback request %s %v", sentPath, sent)
+	}
+
+	// Webhook 返回 5xx 时无法确认是否已送达，不降级
+	webhookCode = 0
+	webhookStatus = http.StatusBadGateway
+	sentPath = ""
+	var apiErr *client.APIError
+	if _, err = replier.Reply(ctx, msg, NewTextMessage("hello")); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502 error, got %v", err)
+	}
+	if sentPath != "" {
+		t.Errorf("Expected no fallback after 502, got %s", sentPath)
+	}
+
+	// Webhook 过期时不请求 Webhook，单聊降级为单聊发送
+	webhookStatus = 0
+	msg.ConversationType = ConversationTypeSingle
+	msg.SessionWebhookExpiredTime = time.Now().Add(-time.Minute).UnixMilli()
+	if result, err = replier.Reply(ctx, msg, NewTextMessage("hello")); err != nil || !result.Fallback {
+		t.Fatalf("Expected fallback, got %+v, %v", result, err)
+	}
+	if sentPath != "/v1.0/robot/oToMessages/batchSend" {
+		t.Errorf("Expected one-to-one fallback, got %s", sentPath)
+	}
+
+	if _, err = replier.Reply(ctx, msg, NewFeedCardMessage().AddLink("t", "https://a", "https://b")); !errors.Is(err, ErrUnsupportedFallback) {
+		t.Errorf("Expected ErrUnsupportedFallback, got %v", err)
+	}
+	if _, err = NewReplier(nil).Reply(ctx, msg, NewTextMessage("hello")); !errors.Is(err, ErrSessionWebhookExpired) {
+		t.Errorf("Expected ErrSessionWebhookExpired, got %v", err)
+	}
+}
+
+func TestPrepareReply(t *testing.T) {
+	msg := ReceiveMsg{
+		ConversationType: ConversationTypeGroup,
+		SenderStaffId:    "user1",
+		SenderNick:       "张三",
+		Msgtype:          TEXT,
+		Text:             Text{Content: "今天天气怎么样"},
+	}
+
+	original := NewTextMessage("晴")
+	reply := msg.PrepareReply(original).(*TextMessage)
+	if reply.Text.Content != "晴" || reply.At == nil || reply.At.AtUserIds[0] != "user1" {
+		t.Errorf("Unexpected text reply %+v %+v", reply.Text, reply.At)
+	}
+	if original.Text.Content != "晴" || original.At != nil {
+		t.Error("Expected original message to be unchanged")
+	}
+
+	if reply = msg.PrepareReply(original, WithMention(false)).(*TextMessage); reply.At != nil {
+		t.Errorf("Expected no mention, got %+v", reply.At)
+	}
+	single := msg
+	single.ConversationType = ConversationTypeSingle
+	if reply = single.PrepareReply(original).(*TextMessage); reply.At != nil {
+		t.Errorf("Expected no mention in single chat by default, got %+v", reply.At)
+	}
+
+	md := msg.PrepareReply(NewMarkdownMessage(markdownTitle("## 日报\n内容"), "## 日报\n内容")).(*MarkDownMessage)
+	if md.MarkDown.Title != "日报" || !strings.HasSuffix(md.MarkDown.Text, "\n\n@user1") {
+		t.Errorf("Unexpected markdown reply %+v", md.MarkDown)
+	}
+	md = msg.PrepareReply(NewMarkdownMessage("t", "内容"), WithTitle("自定义标题"), WithMention(false)).(*MarkDownMessage)
+	if md.MarkDown.Title != "自定义标题" || md.MarkDown.Text != "内容" {
+		t.Errorf("Unexpected markdown reply %+v", md.MarkDown)
+	}
+
+	link := msg.PrepareReply(NewLinkMessage("t", "text", "https://a", ""), WithTitle("新标题")).(*LinkMessage)
+	if link.Link.Title != "新标题" {
+		t.Errorf("Expected custom link title, got %q", link.Link.Title)
+	}
+
+	var sent MarkDownMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer server.Close()
+	msg.SessionWebhook = server.URL
+	if _, err := msg.ReplyImage(context.Background(), "https://a.png", WithMention(false)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sent.MsgType != MARKDOWN || sent.MarkDown.Title != "[图片]" || sent.MarkDown.Text != "![image](https://a.png)" {
+		t.Errorf("Unexpected image reply %+v %+v", sent, sent.MarkDown)
+	}
+}
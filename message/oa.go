@@ -0,0 +1,98 @@
+package message
+
+import "encoding/json"
+
+// OA OA 消息类型，仅用于工作通知
+const OA MsgType = "oa"
+
+// OAMessage OA 消息
+// 文档: https://open.dingtalk.com/document/orgapp/message-types-and-data-format
+type OAMessage struct {
+	MsgType MsgType    `json:"msgtype"`
+	OA      *OAContent `json:"oa"`
+}
+
+// OAContent OA 消息内容
+type OAContent struct {
+	MessageURL   string       `json:"message_url"`
+	PCMessageURL string       `json:"pc_message_url,omitempty"`
+	Head         OAHead       `json:"head"`
+	StatusBar    *OAStatusBar `json:"status_bar,omitempty"`
+	Body         OABody       `json:"body"`
+}
+
+// OAHead OA 消息头部
+type OAHead struct {
+	BgColor string `json:"bgcolor"` // 背景色，ARGB 格式，例如 FFBBBBBB
+	Text    string `json:"text"`
+}
+
+// OAStatusBar OA 消息状态栏
+type OAStatusBar struct {
+	StatusValue string `json:"status_value"`
+	StatusBg    string `json:"status_bg"`
+}
+
+// OABody OA 消息正文
+type OABody struct {
+	Title     string       `json:"title,omitempty"`
+	Form      []OAFormItem `json:"form,omitempty"`
+	Rich      *OARich      `json:"rich,omitempty"`
+	Content   string       `json:"content,omitempty"`
+	Image     string       `json:"image,omitempty"` // 图片 media_id
+	FileCount string       `json:"file_count,omitempty"`
+	Author    string       `json:"author,omitempty"`
+}
+
+// OAFormItem OA 消息表单项
+type OAFormItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// OARich OA 消息单行富文本
+type OARich struct {
+	Num  string `json:"num"`
+	Unit string `json:"unit"`
+}
+
+// NewOAMessage 创建 OA 消息
+func NewOAMessage(messageURL, headText, headBgColor string) *OAMessage {
+	return &OAMessage{MsgType: OA, OA: &OAContent{
+		MessageURL: messageURL,
+		Head:       OAHead{BgColor: headBgColor, Text: headText},
+	}}
+}
+
+// AddForm 添加表单项
+func (m *OAMessage) AddForm(key, value string) *OAMessage {
+	m.OA.Body.Form = append(m.OA.Body.Form, OAFormItem{Key: key, Value: value})
+	return m
+}
+
+// Type 消息类型
+func (m *OAMessage) Type() MsgType {
+	return OA
+}
+
+// Validate 校验消息
+func (m *OAMessage) Validate() error {
+	if m.OA == nil {
+		return fieldRequired(OA, "oa")
+	}
+	if m.OA.MessageURL == "" {
+		return fieldRequired(OA, "oa.message_url")
+	}
+	if m.OA.Head.Text == "" || m.OA.Head.BgColor == "" {
+		return fieldRequired(OA, "oa.head.text/bgcolor")
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *OAMessage) MarshalJSON() ([]byte, error) {
+	type alias OAMessage
+	a := alias(*m)
+	a.MsgType = OA
+	return json.Marshal(a)
+}
@@ -0,0 +1,421 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
+)
+
+// 机器人消息类型
+// 文档: https://open.dingtalk.com/document/orgapp/custom-bot-send-message-type
+const (
+	LINK       MsgType = "link"
+	ACTIONCARD MsgType = "actionCard"
+	FEEDCARD   MsgType = "feedCard"
+)
+
+// ActionCard 按钮排列方向
+const (
+	BtnOrientationVertical   = "0" // 按钮竖直排列
+	BtnOrientationHorizontal = "1" // 按钮横向排列
+)
+
+// MaxTitleLength 标题最大字符数
+const MaxTitleLength = 100
+
+// ErrMissingField 消息缺少必填字段
+var ErrMissingField = errors.New("message: missing required field")
+
+// Message 机器人发送的消息，可用于 Webhook、企业内部机器人与会话回复
+type Message interface {
+	// Type 消息类型
+	Type() MsgType
+	// Validate 校验必填字段与长度限制
+	Validate() error
+}
+
+// Link 链接消息内容
+type Link struct {
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	MessageURL string `json:"messageUrl"`
+	PicURL     string `json:"picUrl,omitempty"`
+}
+
+// LinkMessage 链接消息
+type LinkMessage struct {
+	MsgType MsgType `json:"msgtype"`
+	Link    *Link   `json:"link"`
+}
+
+// ActionCardButton ActionCard 按钮
+type ActionCardButton struct {
+	Title     string `json:"title"`
+	ActionURL string `json:"actionURL"`
+}
+
+// ActionCard ActionCard 消息内容
+// 设置 SingleTitle/SingleURL 为整体跳转，设置 Btns 为独立跳转
+type ActionCard struct {
+	Title          string             `json:"title"`
+	Text           string             `json:"text"`
+	SingleTitle    string             `json:"singleTitle,omitempty"`
+	SingleURL      string             `json:"singleURL,omitempty"`
+	BtnOrientation string             `json:"btnOrientation,omitempty"`
+	Btns           []ActionCardButton `json:"btns,omitempty"`
+}
+
+// ActionCardMessage ActionCard 消息
+type ActionCardMessage struct {
+	MsgType    MsgType     `json:"msgtype"`
+	ActionCard *ActionCard `json:"actionCard"`
+}
+
+// FeedCardLink FeedCard 中的一条链接
+type FeedCardLink struct {
+	Title      string `json:"title"`
+	MessageURL string `json:"messageURL"`
+	PicURL     string `json:"picURL"`
+}
+
+// FeedCard FeedCard 消息内容
+type FeedCard struct {
+	Links []FeedCardLink `json:"links"`
+}
+
+// FeedCardMessage FeedCard 消息
+type FeedCardMessage struct {
+	MsgType  MsgType   `json:"msgtype"`
+	FeedCard *FeedCard `json:"feedCard"`
+}
+
+// NewTextMessage 创建文本消息
+func NewTextMessage(content string) *TextMessage {
+	return &TextMessage{MsgType: TEXT, Text: &Text{Content: content}}
+}
+
+// AtUsers @指定用户
+func (m *TextMessage) AtUsers(userIds ...string) *TextMessage {
+	m.At = m.At.withUsers(userIds)
+	return m
+}
+
+// AtMobiles 通过手机号@指定用户
+func (m *TextMessage) AtMobiles(mobiles ...string) *TextMessage {
+	m.At = m.At.withMobiles(mobiles)
+	return m
+}
+
+// AtAll @所有人
+func (m *TextMessage) AtAll() *TextMessage {
+	m.At = m.At.withAll()
+	return m
+}
+
+// Type 消息类型
+func (m *TextMessage) Type() MsgType {
+	return TEXT
+}
+
+// Validate 校验消息
+func (m *TextMessage) Validate() error {
+	if m.Text == nil || m.Text.Content == "" {
+		return fieldRequired(TEXT, "text.content")
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *TextMessage) MarshalJSON() ([]byte, error) {
+	type alias TextMessage
+	a := alias(*m)
+	a.MsgType = TEXT
+	return json.Marshal(a)
+}
+
+// NewMarkdownMessage 创建 Markdown 消息
+func NewMarkdownMessage(title, text string) *MarkDownMessage {
+	return &MarkDownMessage{MsgType: MARKDOWN, MarkDown: &MarkDown{Title: title, Text: text}}
+}
+
+// AtUsers @指定用户，需要在 text 中包含 @userId 才会高亮
+func (m *MarkDownMessage) AtUsers(userIds ...string) *MarkDownMessage {
+	m.At = m.At.withUsers(userIds)
+	return m
+}
+
+// AtMobiles 通过手机号@指定用户，需要在 text 中包含 @手机号 才会高亮
+func (m *MarkDownMessage) AtMobiles(mobiles ...string) *MarkDownMessage {
+	m.At = m.At.withMobiles(mobiles)
+	return m
+}
+
+// AtAll @所有人
+func (m *MarkDownMessage) AtAll() *MarkDownMessage {
+	m.At = m.At.withAll()
+	return m
+}
+
+// Type 消息类型
+func (m *MarkDownMessage) Type() MsgType {
+	return MARKDOWN
+}
+
+// Validate 校验消息
+func (m *MarkDownMessage) Validate() error {
+	if m.MarkDown == nil {
+		return fieldRequired(MARKDOWN, "markdown")
+	}
+	if err := validateTitle(MARKDOWN, m.MarkDown.Title); err != nil {
+		return err
+	}
+	if m.MarkDown.Text == "" {
+		return fieldRequired(MARKDOWN, "markdown.text")
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *MarkDownMessage) MarshalJSON() ([]byte, error) {
+	type alias MarkDownMessage
+	a := alias(*m)
+	a.MsgType = MARKDOWN
+	return json.Marshal(a)
+}
+
+// NewLinkMessage 创建链接消息
+func NewLinkMessage(title, text, messageURL, picURL string) *LinkMessage {
+	return &LinkMessage{MsgType: LINK, Link: &Link{Title: title, Text: text, MessageURL: messageURL, PicURL: picURL}}
+}
+
+// Type 消息类型
+func (m *LinkMessage) Type() MsgType {
+	return LINK
+}
+
+// Validate 校验消息
+func (m *LinkMessage) Validate() error {
+	if m.Link == nil {
+		return fieldRequired(LINK, "link")
+	}
+	if err := validateTitle(LINK, m.Link.Title); err != nil {
+		return err
+	}
+	if m.Link.Text == "" {
+		return fieldRequired(LINK, "link.text")
+	}
+	if m.Link.MessageURL == "" {
+		return fieldRequired(LINK, "link.messageUrl")
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *LinkMessage) MarshalJSON() ([]byte, error) {
+	type alias LinkMessage
+	a := alias(*m)
+	a.MsgType = LINK
+	return json.Marshal(a)
+}
+
+// NewSingleActionCard 创建整体跳转的 ActionCard 消息
+func NewSingleActionCard(title, text, singleTitle, singleURL string) *ActionCardMessage {
+	return &ActionCardMessage{MsgType: ACTIONCARD, ActionCard: &ActionCard{
+		Title:       title,
+		Text:        text,
+		SingleTitle: singleTitle,
+		SingleURL:   singleURL,
+	}}
+}
+
+// NewActionCard 创建独立跳转的 ActionCard 消息，通过 AddButton 添加按钮
+func NewActionCard(title, text string) *ActionCardMessage {
+	return &ActionCardMessage{MsgType: ACTIONCARD, ActionCard: &ActionCard{Title: title, Text: text}}
+}
+
+// AddButton 添加按钮
+func (m *ActionCardMessage) AddButton(title, actionURL string) *ActionCardMessage {
+	m.ActionCard.Btns = append(m.ActionCard.Btns, ActionCardButton{Title: title, ActionURL: actionURL})
+	return m
+}
+
+// Horizontal 按钮横向排列，默认竖直排列
+func (m *ActionCardMessage) Horizontal() *ActionCardMessage {
+	m.ActionCard.BtnOrientation = BtnOrientationHorizontal
+	return m
+}
+
+// Type 消息类型
+func (m *ActionCardMessage) Type() MsgType {
+	return ACTIONCARD
+}
+
+// Validate 校验消息，整体跳转与独立跳转二选一
+func (m *ActionCardMessage) Validate() error {
+	card := m.ActionCard
+	if card == nil {
+		return fieldRequired(ACTIONCARD, "actionCard")
+	}
+	if err := validateTitle(ACTIONCARD, card.Title); err != nil {
+		return err
+	}
+	if card.Text == "" {
+		return fieldRequired(ACTIONCARD, "actionCard.text")
+	}
+	if card.SingleTitle == "" && card.SingleURL == "" && len(card.Btns) == 0 {
+		return fmt.Errorf("message: %s requires singleTitle/singleURL or btns", ACTIONCARD)
+	}
+	if (card.SingleTitle != "" || card.SingleURL != "") && len(card.Btns) > 0 {
+		return fmt.Errorf("message: %s cannot set both singleTitle/singleURL and btns", ACTIONCARD)
+	}
+	if len(card.Btns) == 0 {
+		if card.SingleTitle == "" {
+			return fieldRequired(ACTIONCARD, "actionCard.singleTitle")
+		}
+		if card.SingleURL == "" {
+			return fieldRequired(ACTIONCARD, "actionCard.singleURL")
+		}
+	}
+	for i, btn := range card.Btns {
+		if btn.Title == "" || btn.ActionURL == "" {
+			return fieldRequired(ACTIONCARD, fmt.Sprintf("actionCard.btns[%d].title/actionURL", i))
+		}
+	}
+	if card.BtnOrientation != "" && card.BtnOrientation != BtnOrientationVertical && card.BtnOrientation != BtnOrientationHorizontal {
+		return fmt.Errorf("message: invalid %s btnOrientation %q", ACTIONCARD, card.BtnOrientation)
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *ActionCardMessage) MarshalJSON() ([]byte, error) {
+	type alias ActionCardMessage
+	a := alias(*m)
+	a.MsgType = ACTIONCARD
+	return json.Marshal(a)
+}
+
+// chatActionCard /chat/send 接口的 action_card 消息内容
+type chatActionCard struct {
+	Title          string                 `json:"title"`
+	Markdown       string                 `json:"markdown"`
+	SingleTitle    string                 `json:"single_title,omitempty"`
+	SingleURL      string                 `json:"single_url,omitempty"`
+	BtnOrientation string                 `json:"btn_orientation,omitempty"`
+	BtnJSONList    []chatActionCardButton `json:"btn_json_list,omitempty"`
+}
+
+type chatActionCardButton struct {
+	Title     string `json:"title"`
+	ActionURL string `json:"action_url"`
+}
+
+// ToChatMessage 转换为 /chat/send 接口的 action_card 格式
+func (m *ActionCardMessage) ToChatMessage() (interface{}, error) {
+	card := m.ActionCard
+	chatCard := &chatActionCard{
+		Title:          card.Title,
+		Markdown:       card.Text,
+		SingleTitle:    card.SingleTitle,
+		SingleURL:      card.SingleURL,
+		BtnOrientation: card.BtnOrientation,
+	}
+	for _, btn := range card.Btns {
+		chatCard.BtnJSONList = append(chatCard.BtnJSONList, chatActionCardButton{Title: btn.Title, ActionURL: btn.ActionURL})
+	}
+	return map[string]interface{}{
+		"msgtype":     "action_card",
+		"action_card": chatCard,
+	}, nil
+}
+
+// NewFeedCardMessage 创建 FeedCard 消息，通过 AddLink 添加链接
+func NewFeedCardMessage() *FeedCardMessage {
+	return &FeedCardMessage{MsgType: FEEDCARD, FeedCard: &FeedCard{}}
+}
+
+// AddLink 添加一条链接
+func (m *FeedCardMessage) AddLink(title, messageURL, picURL string) *FeedCardMessage {
+	m.FeedCard.Links = append(m.FeedCard.Links, FeedCardLink{Title: title, MessageURL: messageURL, PicURL: picURL})
+	return m
+}
+
+// Type 消息类型
+func (m *FeedCardMessage) Type() MsgType {
+	return FEEDCARD
+}
+
+// Validate 校验消息
+func (m *FeedCardMessage) Validate() error {
+	if m.FeedCard == nil || len(m.FeedCard.Links) == 0 {
+		return fieldRequired(FEEDCARD, "feedCard.links")
+	}
+	for i, link := range m.FeedCard.Links {
+		if err := validateTitle(FEEDCARD, link.Title); err != nil {
+			return fmt.Errorf("%w (links[%d])", err, i)
+		}
+		if link.MessageURL == "" || link.PicURL == "" {
+			return fieldRequired(FEEDCARD, fmt.Sprintf("feedCard.links[%d].messageURL/picURL", i))
+		}
+	}
+	return nil
+}
+
+// MarshalJSON 未设置 MsgType 时自动补全
+func (m *FeedCardMessage) MarshalJSON() ([]byte, error) {
+	type alias FeedCardMessage
+	a := alias(*m)
+	a.MsgType = FEEDCARD
+	return json.Marshal(a)
+}
+
+// ToChatMessage /chat/send 接口没有 FeedCard 类型，返回 client.ErrUnsupportedMessage
+func (m *FeedCardMessage) ToChatMessage() (interface{}, error) {
+	return nil, fmt.Errorf("%w: %s cannot be sent via /chat/send", client.ErrUnsupportedMessage, FEEDCARD)
+}
+
+// withUsers 追加@的用户，At 为 nil 时自动创建
+func (a *At) withUsers(userIds []string) *At {
+	if a == nil {
+		a = &At{}
+	}
+	a.AtUserIds = append(a.AtUserIds, userIds...)
+	return a
+}
+
+// withMobiles 追加@的手机号，At 为 nil 时自动创建
+func (a *At) withMobiles(mobiles []string) *At {
+	if a == nil {
+		a = &At{}
+	}
+	a.AtMobiles = append(a.AtMobiles, mobiles...)
+	return a
+}
+
+// withAll 设置@所有人，At 为 nil 时自动创建
+func (a *At) withAll() *At {
+	if a == nil {
+		a = &At{}
+	}
+	a.IsAtAll = true
+	return a
+}
+
+// fieldRequired 必填字段缺失错误
+func fieldRequired(msgType MsgType, field string) error {
+	return fmt.Errorf("%w: %s requires %s", ErrMissingField, msgType, field)
+}
+
+// validateTitle 校验标题非空且不超过 MaxTitleLength
+func validateTitle(msgType MsgType, title string) error {
+	if title == "" {
+		return fieldRequired(msgType, "title")
+	}
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return fmt.Errorf("message: %s title exceeds %d characters", msgType, MaxTitleLength)
+	}
+	return nil
+}
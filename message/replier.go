@@ -0,0 +1,132 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
+)
+
+// 会话类型
+const (
+	ConversationTypeSingle = "1" // 单聊
+	ConversationTypeGroup  = "2" // 群聊
+)
+
+// sessionWebhookMargin 判断 SessionWebhook 过期时预留的时间，避免发送途中过期
+const sessionWebhookMargin = time.Second * 5
+
+var (
+	// ErrSessionWebhookExpired SessionWebhook 已过期且无法降级发送
+	ErrSessionWebhookExpired = errors.New("message: session webhook expired")
+	// ErrUnsupportedFallback 消息类型无法通过企业机器人接口发送
+	ErrUnsupportedFallback = errors.New("message: message type not supported by robot send api")
+)
+
+// SessionWebhookExpired 判断 SessionWebhook 是否已过期，未携带过期时间时视为未过期
+func (r ReceiveMsg) SessionWebhookExpired() bool {
+	if r.SessionWebhookExpiredTime == 0 {
+		return false
+	}
+	return time.Now().Add(sessionWebhookMargin).UnixMilli() >= r.SessionWebhookExpiredTime
+}
+
+// ReplyResult 回复结果
+type ReplyResult struct {
+	Fallback        bool   // 是否通过企业机器人接口发送
+	ProcessQueryKey string // 降级发送时返回，用于查询已读状态与撤回
+}
+
+// Replier 回复机器人收到的消息
+// SessionWebhook 有效时通过 Webhook 回复；已过期、为空或钉钉明确拒绝（4xx 或 errcode）时，
+// 使用企业机器人接口按 RobotCode 与 ConversationID 发送群聊消息，或按发送人 userId 发送单聊消息
+type Replier struct {
+	client *client.DingTalkClient
+}
+
+// NewReplier 创建回复器，dingClient 为 nil 时不降级，Webhook 过期直接返回 ErrSessionWebhookExpired
+func NewReplier(dingClient *client.DingTalkClient) *Replier {
+	return &Replier{client: dingClient}
+}
+
+// Reply 回复消息，opts 见 ReceiveMsg.PrepareReply；Webhook 返回的 errcode 以 *client.APIError 形式返回或触发降级
+// 降级发送时 @ 信息会丢失，且仅支持 ToRobotMessage 可转换的消息类型
+func (r *Replier) Reply(ctx context.Context, msg *ReceiveMsg, reply Message, opts ...ReplyOption) (*ReplyResult, error) {
+	reply = msg.PrepareReply(reply, opts...)
+	if err := reply.Validate(); err != nil {
+		return nil, err
+	}
+
+	webhookErr := ErrSessionWebhookExpired
+	if msg.SessionWebhook != "" && !msg.SessionWebhookExpired() {
+		_, err := msg.ReplyMessageWithContext(ctx, reply)
+		if err == nil {
+			return &ReplyResult{}, nil
+		}
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode >= http.StatusInternalServerError {
+			// 网络错误与 5xx 时无法确定消息是否已送达，不降级以免重复发送
+			return nil, err
+		}
+		webhookErr = err
+	}
+
+	if r.client == nil || msg.RobotCode == "" {
+		return nil, webhookErr
+	}
+	robotMsg, err := ToRobotMessage(reply)
+	if err != nil {
+		return nil, fmt.Errorf("%w (webhook: %v)", err, webhookErr)
+	}
+
+	if msg.ConversationType == ConversationTypeSingle {
+		if msg.SenderStaffId == "" {
+			return nil, fmt.Errorf("message: senderStaffId is required for fallback (webhook: %v)", webhookErr)
+		}
+		result, err := r.client.BatchSendOTOMessageWithContext(ctx, &client.BatchSendOTORequest{
+			RobotCode: msg.RobotCode,
+			UserIDs:   []string{msg.SenderStaffId},
+			Message:   robotMsg,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &ReplyResult{Fallback: true, ProcessQueryKey: result.ProcessQueryKey}, nil
+	}
+
+	result, err := r.client.SendGroupMessageWithContext(ctx, &client.GroupMessageRequest{
+		RobotCode:          msg.RobotCode,
+		OpenConversationID: msg.ConversationID,
+		Message:            robotMsg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ReplyResult{Fallback: true, ProcessQueryKey: result.ProcessQueryKey}, nil
+}
+
+// ToRobotMessage 将消息转换为企业机器人消息模板
+// 支持文本、Markdown、链接与整体跳转的 ActionCard，其他类型返回 ErrUnsupportedFallback
+func ToRobotMessage(msg Message) (client.RobotMessage, error) {
+	switch m := msg.(type) {
+	case *TextMessage:
+		return client.SampleText{Content: m.Text.Content}, nil
+	case *MarkDownMessage:
+		return client.SampleMarkdown{Title: m.MarkDown.Title, Text: m.MarkDown.Text}, nil
+	case *LinkMessage:
+		return client.SampleLink{Title: m.Link.Title, Text: m.Link.Text, MessageURL: m.Link.MessageURL, PicURL: m.Link.PicURL}, nil
+	case *ActionCardMessage:
+		if len(m.ActionCard.Btns) == 0 {
+			return client.SampleActionCard{
+				Title:       m.ActionCard.Title,
+				Text:        m.ActionCard.Text,
+				SingleTitle: m.ActionCard.SingleTitle,
+				SingleURL:   m.ActionCard.SingleURL,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFallback, msg.Type())
+}
@@ -0,0 +1,133 @@
+package message
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// 无法从内容推断标题时使用的标题
+const (
+	defaultReplyTitle = "回复"
+	imageReplyTitle   = "[图片]"
+)
+
+// ReplyOption 回复选项
+type ReplyOption func(*replyOptions)
+
+type replyOptions struct {
+	title   string
+	mention *bool
+}
+
+// WithTitle 设置 Markdown、链接、ActionCard 消息的标题，同时作为会话列表中的预览
+func WithTitle(title string) ReplyOption {
+	return func(o *replyOptions) {
+		o.title = title
+	}
+}
+
+// WithMention 设置是否 @发送人，默认群聊 @、单聊不 @；仅对文本与 Markdown 消息生效
+func WithMention(mention bool) ReplyOption {
+	return func(o *replyOptions) {
+		o.mention = &mention
+	}
+}
+
+// ReplyText 回复文本消息
+func (r ReceiveMsg) ReplyText(ctx context.Context, content string, opts ...ReplyOption) (statuscode int, err error) {
+	return r.ReplyWith(ctx, NewTextMessage(content), opts...)
+}
+
+// ReplyMarkdown 回复 Markdown 消息，未通过 WithTitle 设置标题时取正文第一行
+func (r ReceiveMsg) ReplyMarkdown(ctx context.Context, text string, opts ...ReplyOption) (statuscode int, err error) {
+	return r.ReplyWith(ctx, NewMarkdownMessage(markdownTitle(text), text), opts...)
+}
+
+// ReplyImage 回复图片，SessionWebhook 没有图片消息类型，以只包含图片的 Markdown 消息发送
+// 未通过 WithTitle 设置标题时会话列表中显示为 [图片]
+func (r ReceiveMsg) ReplyImage(ctx context.Context, photoURL string, opts ...ReplyOption) (statuscode int, err error) {
+	return r.ReplyWith(ctx, NewMarkdownMessage(imageReplyTitle, "![image]("+photoURL+")"), opts...)
+}
+
+// ReplyWith 按选项处理后通过 SessionWebhook 回复任意类型的消息
+func (r ReceiveMsg) ReplyWith(ctx context.Context, msg Message, opts ...ReplyOption) (statuscode int, err error) {
+	return r.ReplyMessageWithContext(ctx, r.PrepareReply(msg, opts...))
+}
+
+// PrepareReply 按选项设置标题与 @发送人，返回新的消息，不修改 msg
+func (r ReceiveMsg) PrepareReply(msg Message, opts ...ReplyOption) Message {
+	o := &replyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	mention := r.ConversationType != ConversationTypeSingle
+	if o.mention != nil {
+		mention = *o.mention
+	}
+	mention = mention && r.SenderStaffId != ""
+
+	switch m := msg.(type) {
+	case *TextMessage:
+		if m.Text == nil {
+			return msg
+		}
+		reply := &TextMessage{Text: &Text{Content: m.Text.Content}, At: copyAt(m.At)}
+		if mention {
+			reply.AtUsers(r.SenderStaffId)
+		}
+		return reply
+	case *MarkDownMessage:
+		if m.MarkDown == nil {
+			return msg
+		}
+		reply := &MarkDownMessage{MarkDown: &MarkDown{Title: m.MarkDown.Title, Text: m.MarkDown.Text}, At: copyAt(m.At)}
+		if o.title != "" {
+			reply.MarkDown.Title = o.title
+		}
+		if mention {
+			// Markdown 需要在正文中包含 @userId 才会高亮
+			reply.MarkDown.Text += "\n\n@" + r.SenderStaffId
+			reply.AtUsers(r.SenderStaffId)
+		}
+		return reply
+	case *LinkMessage:
+		if o.title != "" && m.Link != nil {
+			link := *m.Link
+			link.Title = o.title
+			return &LinkMessage{Link: &link}
+		}
+	case *ActionCardMessage:
+		if o.title != "" && m.ActionCard != nil {
+			card := *m.ActionCard
+			card.Title = o.title
+			return &ActionCardMessage{ActionCard: &card}
+		}
+	}
+	return msg
+}
+
+// markdownTitle 取 Markdown 正文第一行作为标题
+func markdownTitle(text string) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+	line = strings.TrimSpace(strings.TrimLeft(line, "#>*- "))
+	if line == "" {
+		return defaultReplyTitle
+	}
+	if utf8.RuneCountInString(line) > MaxTitleLength {
+		line = string([]rune(line)[:MaxTitleLength])
+	}
+	return line
+}
+
+// copyAt 复制 @信息，避免修改调用方的消息
+func copyAt(at *At) *At {
+	if at == nil {
+		return nil
+	}
+	return &At{
+		AtUserIds: append([]string(nil), at.AtUserIds...),
+		AtMobiles: append([]string(nil), at.AtMobiles...),
+		IsAtAll:   at.IsAtAll,
+	}
+}
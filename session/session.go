@@ -0,0 +1,177 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+)
+
+// DefaultTTL 会话默认有效期，每次保存后重新计时
+const DefaultTTL = time.Minute * 30
+
+// Session 单个发送人在单个会话中的状态
+type Session struct {
+	Values    map[string]json.RawMessage `json:"values,omitempty"`
+	Expecting string                     `json:"expecting,omitempty"` // 正在等待的下一条回复，见 Expect
+}
+
+// Get 读取值到 v，不存在时返回 false
+func (s *Session) Get(name string, v interface{}) (bool, error) {
+	raw, ok := s.Values[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Set 写入值，v 需可序列化为 JSON
+func (s *Session) Set(name string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.Values == nil {
+		s.Values = make(map[string]json.RawMessage)
+	}
+	s.Values[name] = raw
+	return nil
+}
+
+// Delete 删除值
+func (s *Session) Delete(name string) {
+	delete(s.Values, name)
+}
+
+// Expect 标记等待用户的下一条回复，name 用于区分不同的提问
+func (s *Session) Expect(name string) {
+	s.Expecting = name
+}
+
+// Reset 清空全部值与等待状态
+func (s *Session) Reset() {
+	s.Values = nil
+	s.Expecting = ""
+}
+
+// empty 没有任何状态
+func (s *Session) empty() bool {
+	return len(s.Values) == 0 && s.Expecting == ""
+}
+
+// Manager 会话管理，按 ConversationID 与发送人标识隔离会话
+type Manager struct {
+	store Store
+	ttl   time.Duration
+
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock 单个会话的锁，refs 为持有或等待的数量，归零时从 locks 中移除
+type keyLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// NewManager 创建会话管理，ttl 小于等于 0 时使用 DefaultTTL
+func NewManager(store Store, ttl time.Duration) *Manager {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Manager{store: store, ttl: ttl, locks: make(map[string]*keyLock)}
+}
+
+// Key 会话在存储中的键
+func Key(msg *message.ReceiveMsg) string {
+	return "dingtalk:session:" + msg.ConversationID + ":" + msg.GetSenderIdentifier()
+}
+
+// Lock 锁定消息所属的会话直到调用返回的 unlock，同一会话的读取、修改与保存应在锁内完成，避免并发消息互相覆盖
+// 锁只在进程内生效，多副本部署时需保证同一会话的消息由同一副本处理
+func (m *Manager) Lock(msg *message.ReceiveMsg) (unlock func()) {
+	key := Key(msg)
+	m.mutex.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mutex.Unlock()
+
+	l.mutex.Lock()
+	return func() {
+		l.mutex.Unlock()
+		m.mutex.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// Update 在会话锁内读取会话并调用 fn 修改，fn 返回 nil 时保存
+func (m *Manager) Update(ctx context.Context, msg *message.ReceiveMsg, fn func(s *Session) error) error {
+	defer m.Lock(msg)()
+	s, err := m.Load(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if err = fn(s); err != nil {
+		return err
+	}
+	return m.Save(ctx, msg, s)
+}
+
+// Load 读取消息所属的会话，不存在时返回空会话
+func (m *Manager) Load(ctx context.Context, msg *message.ReceiveMsg) (*Session, error) {
+	data, err := m.store.Get(ctx, Key(msg))
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{}
+	if len(data) == 0 {
+		return s, nil
+	}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save 保存会话并重新计算有效期，空会话直接删除
+func (m *Manager) Save(ctx context.Context, msg *message.ReceiveMsg, s *Session) error {
+	if s.empty() {
+		return m.store.Delete(ctx, Key(msg))
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return m.store.Set(ctx, Key(msg), data, m.ttl)
+}
+
+// Clear 删除消息所属的会话
+func (m *Manager) Clear(ctx context.Context, msg *message.ReceiveMsg) error {
+	return m.store.Delete(ctx, Key(msg))
+}
+
+// Expect 标记等待发送人在该会话中的下一条回复
+func (m *Manager) Expect(ctx context.Context, msg *message.ReceiveMsg, name string) error {
+	return m.Update(ctx, msg, func(s *Session) error {
+		s.Expect(name)
+		return nil
+	})
+}
+
+// Expecting 返回发送人正在等待的回复标识，没有时返回空字符串
+func (m *Manager) Expecting(ctx context.Context, msg *message.ReceiveMsg) (string, error) {
+	s, err := m.Load(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+	return s.Expecting, nil
+}
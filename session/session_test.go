@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+)
+
+func TestStores(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+	ctx := context.Background()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if data, err := store.Get(ctx, "dingtalk:session:a"); err != nil || data != nil {
+				t.Errorf("Expected nil for missing key, got %q, %v", data, err)
+			}
+			if err := store.Set(ctx, "dingtalk:session:a", []byte(`{"x":1}`), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := store.Get(ctx, "dingtalk:session:a"); string(data) != `{"x":1}` {
+				t.Errorf("Unexpected data %q", data)
+			}
+			if err := store.Delete(ctx, "dingtalk:session:a"); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := store.Get(ctx, "dingtalk:session:a"); data != nil {
+				t.Errorf("Expected deleted key, got %q", data)
+			}
+
+			store.Set(ctx, "dingtalk:session:b", []byte("v"), time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+			if data, _ := store.Get(ctx, "dingtalk:session:b"); data != nil {
+				t.Errorf("Expected expired key, got %q", data)
+			}
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), time.Minute)
+	ctx := context.Background()
+	alice := &message.ReceiveMsg{ConversationID: "cid1", SenderStaffId: "alice"}
+	bob := &message.ReceiveMsg{ConversationID: "cid1", SenderStaffId: "bob"}
+
+	s, err := manager.Load(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("step", 2)
+	s.Expect("confirm")
+	if err = manager.Save(ctx, alice, s); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, _ := manager.Load(ctx, alice)
+	step := 0
+	if ok, err := loaded.Get("step", &step); !ok || err != nil || step != 2 {
+		t.Errorf("Expected step 2, got %d (%v, %v)", step, ok, err)
+	}
+	if expecting, _ := manager.Expecting(ctx, alice); expecting != "confirm" {
+		t.Errorf("Expected confirm, got %q", expecting)
+	}
+	if expecting, _ := manager.Expecting(ctx, bob); expecting != "" {
+		t.Errorf("Expected sessions to be isolated per sender, got %q", expecting)
+	}
+
+	loaded.Reset()
+	manager.Save(ctx, alice, loaded)
+	if data, _ := manager.store.Get(ctx, Key(alice)); data != nil {
+		t.Errorf("Expected empty session to be deleted, got %q", data)
+	}
+}
+
+func TestManagerConcurrentUpdate(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), time.Minute)
+	ctx := context.Background()
+	msg := &message.ReceiveMsg{ConversationID: "cid1", SenderStaffId: "alice"}
+
+	// 同一发送人的并发消息各自读取、修改并保存，不能互相覆盖
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := manager.Update(ctx, msg, func(s *Session) error {
+				var count int
+				s.Get("count", &count)
+				time.Sleep(time.Millisecond)
+				return s.Set("count", count+1)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := manager.Load(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if s.Get("count", &count); count != 50 {
+		t.Errorf("Expected count 50, got %d", count)
+	}
+	if len(manager.locks) != 0 {
+		t.Errorf("Expected locks to be released, got %d", len(manager.locks))
+	}
+}
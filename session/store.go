@@ -0,0 +1,178 @@
+// Package session 提供按会话与发送人隔离的机器人会话状态存储，用于多步对话（向导、确认）等场景
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/difyz9/dingtalk-sdk.git/internal/fileutil"
+	"github.com/difyz9/dingtalk-sdk.git/internal/resp"
+)
+
+// Store 会话数据存储
+type Store interface {
+	// Get 读取数据，不存在或已过期时返回 nil
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set 写入数据，ttl 到期后自动删除
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	// Delete 删除数据
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore 进程内会话存储
+type MemoryStore struct {
+	mutex     sync.Mutex
+	entries   map[string]storedEntry
+	lastPrune time.Time
+}
+
+type storedEntry struct {
+	Data     []byte    `json:"data"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// NewMemoryStore 创建进程内会话存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]storedEntry)}
+}
+
+// Get 读取数据
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.ExpireAt) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry.Data, nil
+}
+
+// Set 写入数据，并定期清理过期数据
+func (s *MemoryStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Minute {
+		for k, entry := range s.entries {
+			if now.After(entry.ExpireAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastPrune = now
+	}
+	s.entries[key] = storedEntry{Data: data, ExpireAt: now.Add(ttl)}
+	return nil
+}
+
+// Delete 删除数据
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// FileStore 基于文件的会话存储，适用于单机部署，重启后会话不丢失
+// 每个键对应目录下的一个 JSON 文件，过期文件在读取时删除
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建文件会话存储，dir 不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path 键对应的文件路径
+func (s *FileStore) path(key string) string {
+	return fileutil.Path(s.dir, key, ".json")
+}
+
+// Get 读取数据
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := storedEntry{}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if time.Now().After(entry.ExpireAt) {
+		os.Remove(s.path(key))
+		return nil, nil
+	}
+	return entry.Data, nil
+}
+
+// Set 写入数据
+func (s *FileStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	content, err := json.Marshal(storedEntry{Data: data, ExpireAt: time.Now().Add(ttl)})
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFile(s.path(key), content)
+}
+
+// Delete 删除数据
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// RedisStore 基于 Redis 协议的会话存储，适用于多副本部署，兼容 Redis、KeyDB、Valkey 等
+type RedisStore struct {
+	client *resp.Client
+}
+
+// RedisConfig Redis 连接配置，与 client.RedisConfig 为同一类型
+type RedisConfig = resp.Options
+
+// NewRedisStore 创建 Redis 会话存储
+func NewRedisStore(config RedisConfig) *RedisStore {
+	return &RedisStore{client: resp.NewClient(config)}
+}
+
+// Get 读取数据
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := s.client.Do(ctx, "GET", key)
+	if errors.Is(err, resp.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, _ := reply.(string)
+	return []byte(data), nil
+}
+
+// Set 写入数据，键随 ttl 过期
+func (s *RedisStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	_, err := s.client.Do(ctx, "SET", key, data, "PX", ttl.Milliseconds())
+	return err
+}
+
+// Delete 删除数据
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.Do(ctx, "DEL", key)
+	return err
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,328 @@
+package stream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	dingtalk "github.com/alibabacloud-go/dingtalk/card_1_0"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/card"
+
+	"github.com/difyz9/dingtalk-sdk.git/internal/httperr"
+)
+
+// maxCallbackBodySize HTTP 卡片回调请求体上限
+const maxCallbackBodySize = 1 << 20
+
+// CardAction 卡片回调，按钮点击与表单提交时由钉钉推送
+type CardAction struct {
+	OutTrackID string
+	SpaceID    string
+	SpaceType  string
+	UserID     string
+	CorpID     string
+	Type       string
+	ActionIDs  []string                   // 触发回调的组件 actionId
+	Params     map[string]json.RawMessage // 回调参数，表单提交时为各字段的值
+	Content    string                     // 原始的 content 字段
+}
+
+// ActionID 返回第一个 actionId
+func (a *CardAction) ActionID() string {
+	if len(a.ActionIDs) == 0 {
+		return ""
+	}
+	return a.ActionIDs[0]
+}
+
+// Param 返回字符串参数，不存在或不是字符串时返回空字符串
+func (a *CardAction) Param(name string) string {
+	var value string
+	if raw, ok := a.Params[name]; ok {
+		json.Unmarshal(raw, &value)
+	}
+	return value
+}
+
+// Bind 将回调参数解析到 v，v 通常为带 json 标签的结构体指针
+func (a *CardAction) Bind(v interface{}) error {
+	data, err := json.Marshal(a.Params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// CardUpdate 回调响应中的卡片更新，公有数据对所有人生效，私有数据只对触发回调的用户生效
+type CardUpdate struct {
+	CardData               CardData
+	PrivateData            CardData
+	UpdateCardDataByKey    bool // 为 true 时只更新 CardData 中的变量
+	UpdatePrivateDataByKey bool // 为 true 时只更新 PrivateData 中的变量
+}
+
+// toResponse 转换为回调响应，update 为 nil 时不更新卡片
+func (u *CardUpdate) toResponse() *card.CardResponse {
+	resp := &card.CardResponse{}
+	if u == nil {
+		return resp
+	}
+	resp.CardUpdateOptions = &card.CardUpdateOptions{
+		UpdateCardDataByKey:    u.UpdateCardDataByKey,
+		UpdatePrivateDataByKey: u.UpdatePrivateDataByKey,
+	}
+	if u.CardData != nil {
+		resp.CardData = &card.CardDataDto{CardParamMap: u.CardData}
+	}
+	if u.PrivateData != nil {
+		resp.UserPrivateData = &card.CardDataDto{CardParamMap: u.PrivateData}
+	}
+	return resp
+}
+
+// CardActionHandler 卡片回调处理函数，返回 nil 时不更新卡片
+type CardActionHandler func(ctx context.Context, action *CardAction) (*CardUpdate, error)
+
+// ErrCallbackUnverified HTTP 卡片回调未通过来源校验
+var ErrCallbackUnverified = errors.New("stream: card callback verification failed")
+
+// CardCallbackVerifier 校验 HTTP 卡片回调的来源，body 为原始请求体，返回错误时拒绝请求
+type CardCallbackVerifier func(req *http.Request, body []byte) error
+
+// QueryTokenVerifier 校验回调地址中的查询参数 name 是否等于 token
+// 注册回调时在 CallbackURL 中带上随机生成的 token，例如 https://example.com/card?token=<token>
+func QueryTokenVerifier(name, token string) CardCallbackVerifier {
+	return func(req *http.Request, body []byte) error {
+		value := req.URL.Query().Get(name)
+		if token == "" || subtle.ConstantTimeCompare([]byte(value), []byte(token)) != 1 {
+			return ErrCallbackUnverified
+		}
+		return nil
+	}
+}
+
+// CardCallbackRouter 卡片回调路由，依次按 OutTrackID、actionId 匹配处理函数
+type CardCallbackRouter struct {
+	mutex    sync.RWMutex
+	cards    map[string]CardActionHandler
+	actions  map[string]CardActionHandler
+	fallback CardActionHandler
+	verifier CardCallbackVerifier
+
+	// ErrorLog 记录 ServeHTTP 中处理函数返回的错误，为 nil 时使用 log.Default()
+	ErrorLog *log.Logger
+}
+
+// NewCardCallbackRouter 创建卡片回调路由
+func NewCardCallbackRouter() *CardCallbackRouter {
+	return &CardCallbackRouter{
+		cards:   make(map[string]CardActionHandler),
+		actions: make(map[string]CardActionHandler),
+	}
+}
+
+// HandleAction 注册指定 actionId 的处理函数，handler 为 nil 时取消注册
+func (r *CardCallbackRouter) HandleAction(actionID string, handler CardActionHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if handler == nil {
+		delete(r.actions, actionID)
+		return
+	}
+	r.actions[actionID] = handler
+}
+
+// HandleCard 注册指定卡片的处理函数，优先于 HandleAction，handler 为 nil 时取消注册
+func (r *CardCallbackRouter) HandleCard(outTrackID string, handler CardActionHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if handler == nil {
+		delete(r.cards, outTrackID)
+		return
+	}
+	r.cards[outTrackID] = handler
+}
+
+// HandleDefault 注册未匹配时的处理函数，未注册时不更新卡片
+func (r *CardCallbackRouter) HandleDefault(handler CardActionHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fallback = handler
+}
+
+// SetVerifier 设置 HTTP 回调的来源校验，未设置时 ServeHTTP 拒绝所有请求，见 QueryTokenVerifier
+func (r *CardCallbackRouter) SetVerifier(verifier CardCallbackVerifier) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.verifier = verifier
+}
+
+// Dispatch 分发卡片回调
+func (r *CardCallbackRouter) Dispatch(ctx context.Context, action *CardAction) (*CardUpdate, error) {
+	r.mutex.RLock()
+	handler, ok := r.cards[action.OutTrackID]
+	if !ok {
+		for _, actionID := range action.ActionIDs {
+			if handler, ok = r.actions[actionID]; ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		handler = r.fallback
+	}
+	r.mutex.RUnlock()
+
+	if handler == nil {
+		return nil, nil
+	}
+	return handler(ctx, action)
+}
+
+// CardCallbackHandler 返回 Stream 模式的卡片回调，可传给 StreamClient.RegisterCardCallbackRouter
+// 卡片创建时未指定 CallbackRouteKey 即使用 Stream 模式回调
+func (r *CardCallbackRouter) CardCallbackHandler() card.ICardCallbackHandler {
+	return func(ctx context.Context, request *card.CardRequest) (*card.CardResponse, error) {
+		action, err := parseCardAction(&cardCallbackRequest{
+			Content:    request.Content,
+			CorpID:     request.CorpId,
+			OutTrackID: request.OutTrackId,
+			SpaceID:    request.SpaceId,
+			SpaceType:  request.SpaceType,
+			Type:       request.Type,
+			UserID:     request.UserId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		update, err := r.Dispatch(ctx, action)
+		if err != nil {
+			return nil, err
+		}
+		return update.toResponse(), nil
+	}
+}
+
+// ServeHTTP 处理 HTTP 模式的卡片回调，响应体为卡片更新
+// 卡片需通过 RegisterCallback 注册的 callbackRouteKey 创建；请求需通过 SetVerifier 设置的来源校验
+func (r *CardCallbackRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxCallbackBodySize))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	r.mutex.RLock()
+	verifier := r.verifier
+	r.mutex.RUnlock()
+	if verifier == nil {
+		err = ErrCallbackUnverified
+	} else {
+		err = verifier(req, data)
+	}
+	if err != nil {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	body := &cardCallbackRequest{}
+	if err = json.Unmarshal(data, body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	action, err := parseCardAction(body)
+	if err != nil {
+		http.Error(w, "invalid content", http.StatusBadRequest)
+		return
+	}
+	update, err := r.Dispatch(req.Context(), action)
+	if err != nil {
+		httperr.Internal(w, r.ErrorLog, "stream", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update.toResponse())
+}
+
+// cardCallbackRequest 卡片回调请求，Stream 与 HTTP 模式字段一致
+type cardCallbackRequest struct {
+	Content    string `json:"content"`
+	CorpID     string `json:"corpId"`
+	OutTrackID string `json:"outTrackId"`
+	SpaceID    string `json:"spaceId"`
+	SpaceType  string `json:"spaceType"`
+	Type       string `json:"type"`
+	UserID     string `json:"userId"`
+}
+
+// parseCardAction 解析 content 中的 actionId 与回调参数
+func parseCardAction(req *cardCallbackRequest) (*CardAction, error) {
+	action := &CardAction{
+		OutTrackID: req.OutTrackID,
+		SpaceID:    req.SpaceID,
+		SpaceType:  req.SpaceType,
+		UserID:     req.UserID,
+		CorpID:     req.CorpID,
+		Type:       req.Type,
+		Content:    req.Content,
+	}
+	if req.Content == "" {
+		return action, nil
+	}
+	content := struct {
+		CardPrivateData struct {
+			ActionIDs []string                   `json:"actionIds"`
+			Params    map[string]json.RawMessage `json:"params"`
+		} `json:"cardPrivateData"`
+	}{}
+	if err := json.Unmarshal([]byte(req.Content), &content); err != nil {
+		return nil, err
+	}
+	action.ActionIDs = content.CardPrivateData.ActionIDs
+	action.Params = content.CardPrivateData.Params
+	return action, nil
+}
+
+// RegisterCallbackRequest 注册 HTTP 卡片回调地址请求
+type RegisterCallbackRequest struct {
+	CallbackRouteKey string // 创建卡片时通过 CallbackRouteKey 指定
+	CallbackURL      string // 回调地址，可带上 QueryTokenVerifier 校验的 token 参数
+	APISecret        string
+	ForceUpdate      bool // 为 true 时覆盖已注册的同名 callbackRouteKey
+}
+
+// RegisterCallback 注册 HTTP 卡片回调地址
+func (s *StreamCardClient) RegisterCallback(accessToken string, req *RegisterCallbackRequest) error {
+	return s.RegisterCallbackWithContext(context.Background(), accessToken, req)
+}
+
+// RegisterCallbackWithContext 注册 HTTP 卡片回调地址，ctx 取消时立即返回
+func (s *StreamCardClient) RegisterCallbackWithContext(ctx context.Context, accessToken string, req *RegisterCallbackRequest) error {
+	registerReq := &dingtalk.RegisterCallbackRequest{
+		CallbackRouteKey: tea.String(req.CallbackRouteKey),
+		CallbackUrl:      tea.String(req.CallbackURL),
+		ForceUpdate:      tea.Bool(req.ForceUpdate),
+	}
+	if req.APISecret != "" {
+		registerReq.SetApiSecret(req.APISecret)
+	}
+
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/callbacks/register", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.RegisterCallbackHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.RegisterCallbackWithOptions(registerReq, headers, runtime)
+		return err
+	})
+}
@@ -0,0 +1,285 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	dingtalk "github.com/alibabacloud-go/dingtalk/card_1_0"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// 卡片投放空间类型
+const (
+	SpaceTypeIMGroup  = "IM_GROUP"  // 群聊
+	SpaceTypeIMRobot  = "IM_ROBOT"  // 机器人单聊
+	SpaceTypeIMSingle = "IM_SINGLE" // 人与人单聊
+)
+
+// openSpaceIDPrefix openSpaceId 固定前缀，多个空间以 ; 分隔，如 dtv1.card//IM_GROUP.cid;IM_ROBOT.userId
+const openSpaceIDPrefix = "dtv1.card//"
+
+// userIDTypeUserID 接口中的用户 ID 使用 userId
+const userIDTypeUserID = 1
+
+// CardData 卡片公有数据，对应模板中的变量；值只能是字符串，数组、对象等复杂类型需 JSON 编码，见 SetJSON
+type CardData map[string]string
+
+// SetJSON 将 v 编码为 JSON 后设置为变量 key 的值，用于列表、对象等复杂类型变量
+func (d CardData) SetJSON(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	d[key] = string(data)
+	return nil
+}
+
+// paramMap 转换为接口的 cardParamMap
+func (d CardData) paramMap() map[string]*string {
+	params := make(map[string]*string, len(d))
+	for k, v := range d {
+		params[k] = tea.String(v)
+	}
+	return params
+}
+
+// PrivateData 按 userId 区分的私有数据，每个用户看到的变量值以私有数据优先
+type PrivateData map[string]CardData
+
+// toSDK 转换为接口的 privateData
+func (p PrivateData) toSDK() map[string]*dingtalk.PrivateDataValue {
+	if len(p) == 0 {
+		return nil
+	}
+	values := make(map[string]*dingtalk.PrivateDataValue, len(p))
+	for userID, data := range p {
+		values[userID] = &dingtalk.PrivateDataValue{CardParamMap: data.paramMap()}
+	}
+	return values
+}
+
+// callbackType 根据 callbackRouteKey 选择回调方式
+func callbackType(callbackRouteKey string) string {
+	if callbackRouteKey != "" {
+		return "HTTP"
+	}
+	return "STREAM"
+}
+
+// GroupSpaceID 群聊的投放空间 ID
+func GroupSpaceID(openConversationID string) string {
+	return openSpaceIDPrefix + SpaceTypeIMGroup + "." + openConversationID
+}
+
+// RobotSpaceID 机器人单聊的投放空间 ID
+func RobotSpaceID(userID string) string {
+	return openSpaceIDPrefix + SpaceTypeIMRobot + "." + userID
+}
+
+// spaceTypes 解析 openSpaceId 中的空间类型
+func spaceTypes(openSpaceID string) map[string]bool {
+	types := make(map[string]bool)
+	for _, space := range strings.Split(strings.TrimPrefix(openSpaceID, openSpaceIDPrefix), ";") {
+		if spaceType, _, ok := strings.Cut(space, "."); ok {
+			types[spaceType] = true
+		}
+	}
+	return types
+}
+
+// CreateCardRequest 创建卡片实例请求
+type CreateCardRequest struct {
+	CardTemplateID   string
+	OutTrackID       string
+	CardData         CardData
+	PrivateData      PrivateData
+	CallbackRouteKey string // 使用 HTTP 回调时填写 RegisterCallback 注册的 key，为空时通过 Stream 模式接收回调
+}
+
+// CreateCard 创建卡片实例但不投放，之后通过 DeliverCard 投放到一个或多个空间
+func (s *StreamCardClient) CreateCard(accessToken string, req *CreateCardRequest) error {
+	return s.CreateCardWithContext(context.Background(), accessToken, req)
+}
+
+// CreateCardWithContext 创建卡片实例但不投放，创建时声明群聊与机器人单聊空间，ctx 取消时立即返回
+func (s *StreamCardClient) CreateCardWithContext(ctx context.Context, accessToken string, req *CreateCardRequest) error {
+	createReq := &dingtalk.CreateCardRequest{
+		CardTemplateId: tea.String(req.CardTemplateID),
+		OutTrackId:     tea.String(req.OutTrackID),
+		CardData:       &dingtalk.CreateCardRequestCardData{CardParamMap: req.CardData.paramMap()},
+		PrivateData:    req.PrivateData.toSDK(),
+		CallbackType:   tea.String(callbackType(req.CallbackRouteKey)),
+		UserIdType:     tea.Int32(userIDTypeUserID),
+		ImGroupOpenSpaceModel: &dingtalk.CreateCardRequestImGroupOpenSpaceModel{
+			SupportForward: tea.Bool(true),
+		},
+		ImRobotOpenSpaceModel: &dingtalk.CreateCardRequestImRobotOpenSpaceModel{
+			SupportForward: tea.Bool(true),
+		},
+	}
+
+	if req.CallbackRouteKey != "" {
+		createReq.SetCallbackRouteKey(req.CallbackRouteKey)
+	}
+
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/instances", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.CreateCardHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.CreateCardWithOptions(createReq, headers, runtime)
+		return err
+	})
+}
+
+// DeliverCardRequest 投放卡片请求
+type DeliverCardRequest struct {
+	OutTrackID  string
+	OpenSpaceID string   // 投放空间，多个空间以 ; 分隔，见 GroupSpaceID、RobotSpaceID
+	RobotCode   string   // 群聊与机器人单聊投放时使用的机器人
+	Recipients  []string // 群聊中仅对指定 userId 可见，为空时全员可见
+}
+
+// DeliverResult 单个空间的投放结果
+type DeliverResult struct {
+	SpaceType string
+	SpaceID   string
+	CarrierID string
+	Success   bool
+	ErrorMsg  string
+}
+
+// DeliverCard 将已创建的卡片投放到空间
+func (s *StreamCardClient) DeliverCard(accessToken string, req *DeliverCardRequest) ([]DeliverResult, error) {
+	return s.DeliverCardWithContext(context.Background(), accessToken, req)
+}
+
+// DeliverCardWithContext 将已创建的卡片投放到空间，返回每个空间的投放结果，ctx 取消时立即返回
+// 投放到创建时未声明的空间类型前需要先调用 AppendSpace
+func (s *StreamCardClient) DeliverCardWithContext(ctx context.Context, accessToken string, req *DeliverCardRequest) ([]DeliverResult, error) {
+	deliverReq := buildDeliverRequest(req)
+
+	var response *dingtalk.DeliverCardResponse
+	err := s.callWithToken(ctx, accessToken, "/v1.0/card/instances/deliver", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.DeliverCardHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		var err error
+		response, err = s.client.DeliverCardWithOptions(deliverReq, headers, runtime)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DeliverResult
+	if response != nil && response.Body != nil {
+		for _, r := range response.Body.Result {
+			results = append(results, DeliverResult{
+				SpaceType: tea.StringValue(r.SpaceType),
+				SpaceID:   tea.StringValue(r.SpaceId),
+				CarrierID: tea.StringValue(r.CarrierId),
+				Success:   tea.BoolValue(r.Success),
+				ErrorMsg:  tea.StringValue(r.ErrorMsg),
+			})
+		}
+	}
+	return results, nil
+}
+
+// buildDeliverRequest 按 OpenSpaceID 中的空间类型设置投放模型
+func buildDeliverRequest(req *DeliverCardRequest) *dingtalk.DeliverCardRequest {
+	deliverReq := &dingtalk.DeliverCardRequest{
+		OutTrackId:  tea.String(req.OutTrackID),
+		OpenSpaceId: tea.String(req.OpenSpaceID),
+		UserIdType:  tea.Int32(userIDTypeUserID),
+	}
+	types := spaceTypes(req.OpenSpaceID)
+	if types[SpaceTypeIMGroup] {
+		model := &dingtalk.DeliverCardRequestImGroupOpenDeliverModel{
+			Recipients: tea.StringSlice(req.Recipients),
+		}
+		if req.RobotCode != "" {
+			model.SetRobotCode(req.RobotCode)
+		}
+		deliverReq.SetImGroupOpenDeliverModel(model)
+	}
+	if types[SpaceTypeIMRobot] {
+		model := &dingtalk.DeliverCardRequestImRobotOpenDeliverModel{
+			SpaceType: tea.String(SpaceTypeIMRobot),
+		}
+		if req.RobotCode != "" {
+			model.SetRobotCode(req.RobotCode)
+		}
+		deliverReq.SetImRobotOpenDeliverModel(model)
+	}
+	if types[SpaceTypeIMSingle] {
+		deliverReq.SetImSingleOpenDeliverModel(&dingtalk.DeliverCardRequestImSingleOpenDeliverModel{})
+	}
+	return deliverReq
+}
+
+// AppendSpace 为已创建的卡片追加群聊与机器人单聊空间
+func (s *StreamCardClient) AppendSpace(accessToken, outTrackID string) error {
+	return s.AppendSpaceWithContext(context.Background(), accessToken, outTrackID)
+}
+
+// AppendSpaceWithContext 为已创建的卡片追加群聊与机器人单聊空间，之后可投放到这些空间，ctx 取消时立即返回
+func (s *StreamCardClient) AppendSpaceWithContext(ctx context.Context, accessToken, outTrackID string) error {
+	appendReq := &dingtalk.AppendSpaceRequest{
+		OutTrackId: tea.String(outTrackID),
+		ImGroupOpenSpaceModel: &dingtalk.AppendSpaceRequestImGroupOpenSpaceModel{
+			SupportForward: tea.Bool(true),
+		},
+		ImRobotOpenSpaceModel: &dingtalk.AppendSpaceRequestImRobotOpenSpaceModel{
+			SupportForward: tea.Bool(true),
+		},
+	}
+
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/instances/spaces", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.AppendSpaceHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.AppendSpaceWithOptions(appendReq, headers, runtime)
+		return err
+	})
+}
+
+// UpdateCardRequest 更新卡片请求
+type UpdateCardRequest struct {
+	OutTrackID             string
+	CardData               CardData
+	PrivateData            PrivateData
+	UpdateCardDataByKey    bool // 为 true 时只更新 CardData 中的变量，默认覆盖全部公有数据
+	UpdatePrivateDataByKey bool // 为 true 时只更新 PrivateData 中的变量，默认覆盖对应用户的全部私有数据
+}
+
+// UpdateCard 更新卡片的公有数据与按用户的私有数据
+func (s *StreamCardClient) UpdateCard(accessToken string, req *UpdateCardRequest) error {
+	return s.UpdateCardWithContext(context.Background(), accessToken, req)
+}
+
+// UpdateCardWithContext 更新卡片的公有数据与按用户的私有数据，ctx 取消时立即返回
+func (s *StreamCardClient) UpdateCardWithContext(ctx context.Context, accessToken string, req *UpdateCardRequest) error {
+	updateReq := &dingtalk.UpdateCardRequest{
+		OutTrackId:  tea.String(req.OutTrackID),
+		PrivateData: req.PrivateData.toSDK(),
+		UserIdType:  tea.Int32(userIDTypeUserID),
+		CardUpdateOptions: &dingtalk.UpdateCardRequestCardUpdateOptions{
+			UpdateCardDataByKey:    tea.Bool(req.UpdateCardDataByKey),
+			UpdatePrivateDataByKey: tea.Bool(req.UpdatePrivateDataByKey),
+		},
+	}
+	if req.CardData != nil {
+		updateReq.SetCardData(&dingtalk.UpdateCardRequestCardData{CardParamMap: req.CardData.paramMap()})
+	}
+
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/instances", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.UpdateCardHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.UpdateCardWithOptions(updateReq, headers, runtime)
+		return err
+	})
+}
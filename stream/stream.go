@@ -1,21 +1,44 @@
 package stream
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
 	dingtalk "github.com/alibabacloud-go/dingtalk/card_1_0"
 	util "github.com/alibabacloud-go/tea-utils/v2/service"
 	"github.com/alibabacloud-go/tea/tea"
 	"github.com/google/uuid"
+
+	"github.com/difyz9/dingtalk-sdk.git/client"
+	"github.com/difyz9/dingtalk-sdk.git/message"
 )
 
-// StreamCardClient 流式卡片客户端
+// ErrNoAccessToken 未传入 accessToken 且客户端没有配置 TokenProvider
+var ErrNoAccessToken = errors.New("stream: access token is required")
+
+// TokenProvider 获取新版接口使用的 AccessToken，*client.DingTalkClient 实现了该接口
+type TokenProvider interface {
+	GetAccessTokenWithContext(ctx context.Context) (string, error)
+}
+
+// tokenInvalidator 可使指定的 AccessToken 失效，TokenProvider 实现该接口时 Token 失效会刷新后重试一次
+// 仅当缓存的仍是 accessToken 时才失效，避免丢弃其他协程刚刷新的 Token，见 client.DingTalkClient.InvalidateAccessTokenIfCurrent
+type tokenInvalidator interface {
+	InvalidateAccessTokenIfCurrent(accessToken string)
+}
+
+// StreamCardClient 流式卡片客户端，可并发使用，应在多次调用间复用
 type StreamCardClient struct {
-	client *dingtalk.Client
+	client        *dingtalk.Client
+	tokenProvider TokenProvider
 }
 
-// NewStreamCardClient 创建流式卡片客户端
+// NewStreamCardClient 创建流式卡片客户端，每次调用需要传入 accessToken
 func NewStreamCardClient() (*StreamCardClient, error) {
 	config := &openapi.Config{}
 	config.Protocol = tea.String("https")
@@ -29,6 +52,103 @@ func NewStreamCardClient() (*StreamCardClient, error) {
 	}, nil
 }
 
+// NewStreamCardClientWithTokenProvider 创建自行管理 AccessToken 的流式卡片客户端
+// 各方法的 accessToken 传空字符串时通过 provider 获取（如 *client.DingTalkClient，自动缓存与刷新），
+// Token 失效时刷新后重试一次
+func NewStreamCardClientWithTokenProvider(provider TokenProvider) (*StreamCardClient, error) {
+	cardClient, err := NewStreamCardClient()
+	if err != nil {
+		return nil, err
+	}
+	cardClient.tokenProvider = provider
+	return cardClient, nil
+}
+
+// callWithToken 使用 accessToken 执行调用，accessToken 为空时从 TokenProvider 获取
+func (s *StreamCardClient) callWithToken(ctx context.Context, accessToken, endpoint string, call func(accessToken string, runtime *util.RuntimeOptions) error) error {
+	if accessToken != "" {
+		return callWithContext(ctx, endpoint, func(runtime *util.RuntimeOptions) error {
+			return call(accessToken, runtime)
+		})
+	}
+	if s.tokenProvider == nil {
+		return ErrNoAccessToken
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := s.tokenProvider.GetAccessTokenWithContext(ctx)
+		if err != nil {
+			return err
+		}
+		err = callWithContext(ctx, endpoint, func(runtime *util.RuntimeOptions) error {
+			return call(token, runtime)
+		})
+		invalidator, ok := s.tokenProvider.(tokenInvalidator)
+		if attempt > 0 || !ok || !client.IsTokenExpired(err) {
+			return err
+		}
+		invalidator.InvalidateAccessTokenIfCurrent(token)
+	}
+}
+
+// runtimeOptions 根据 ctx 的截止时间生成 tea 运行时参数
+func runtimeOptions(ctx context.Context) *util.RuntimeOptions {
+	runtime := &util.RuntimeOptions{}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := int(time.Until(deadline) / time.Millisecond)
+		if timeout < 1 {
+			timeout = 1
+		}
+		runtime.ReadTimeout = tea.Int(timeout)
+		runtime.ConnectTimeout = tea.Int(timeout)
+	}
+	return runtime
+}
+
+// callWithContext 在 ctx 控制下执行 tea SDK 调用，接口错误转换为 *client.APIError
+// tea SDK 本身不支持 context：ctx 取消时立即返回 ctx.Err()，底层请求由截止时间换算的超时兜底结束
+func callWithContext(ctx context.Context, endpoint string, call func(runtime *util.RuntimeOptions) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- call(runtimeOptions(ctx))
+	}()
+	select {
+	case err := <-done:
+		return toAPIError(endpoint, err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// toAPIError 将 tea SDK 返回的 *tea.SDKError 转换为 *client.APIError，其他错误原样返回
+func toAPIError(endpoint string, err error) error {
+	var sdkErr *tea.SDKError
+	if !errors.As(err, &sdkErr) {
+		return err
+	}
+	apiErr := &client.APIError{
+		Code:       tea.StringValue(sdkErr.Code),
+		ErrMsg:     tea.StringValue(sdkErr.Message),
+		StatusCode: tea.IntValue(sdkErr.StatusCode),
+		Endpoint:   endpoint,
+		Body:       []byte(tea.StringValue(sdkErr.Data)),
+	}
+	data := struct {
+		RequestID  string `json:"requestid"`
+		RequestID2 string `json:"requestId"`
+	}{}
+	if json.Unmarshal(apiErr.Body, &data) == nil {
+		apiErr.RequestID = data.RequestID
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = data.RequestID2
+		}
+	}
+	return apiErr
+}
+
 // CreateAndDeliverCardRequest 创建并投放卡片请求
 type CreateAndDeliverCardRequest struct {
 	CardTemplateID   string
@@ -38,28 +158,25 @@ type CreateAndDeliverCardRequest struct {
 	RobotCode        string
 	OpenSpaceID      string
 	ConversationType string // "1" for private chat, "2" for group chat
-	CardData         map[string]string
+	CardData         CardData
+	PrivateData      PrivateData
+	CallbackRouteKey string // 使用 HTTP 回调时填写 RegisterCallback 注册的 key，为空时通过 Stream 模式接收回调
 }
 
 // CreateAndDeliverCard 创建并投放流式卡片
 func (s *StreamCardClient) CreateAndDeliverCard(accessToken string, req *CreateAndDeliverCardRequest) error {
-	headers := &dingtalk.CreateAndDeliverHeaders{
-		XAcsDingtalkAccessToken: tea.String(accessToken),
-	}
-
-	cardData := &dingtalk.CreateAndDeliverRequestCardData{
-		CardParamMap: make(map[string]*string),
-	}
-	for k, v := range req.CardData {
-		cardData.CardParamMap[k] = tea.String(v)
-	}
+	return s.CreateAndDeliverCardWithContext(context.Background(), accessToken, req)
+}
 
+// CreateAndDeliverCardWithContext 创建并投放流式卡片，ctx 取消时立即返回
+func (s *StreamCardClient) CreateAndDeliverCardWithContext(ctx context.Context, accessToken string, req *CreateAndDeliverCardRequest) error {
 	createReq := &dingtalk.CreateAndDeliverRequest{
 		CardTemplateId: tea.String(req.CardTemplateID),
 		OutTrackId:     tea.String(req.OutTrackID),
-		CardData:       cardData,
-		CallbackType:   tea.String("STREAM"),
-		UserIdType:     tea.Int32(1),
+		CardData:       &dingtalk.CreateAndDeliverRequestCardData{CardParamMap: req.CardData.paramMap()},
+		PrivateData:    req.PrivateData.toSDK(),
+		CallbackType:   tea.String(callbackType(req.CallbackRouteKey)),
+		UserIdType:     tea.Int32(userIDTypeUserID),
 		ImGroupOpenSpaceModel: &dingtalk.CreateAndDeliverRequestImGroupOpenSpaceModel{
 			SupportForward: tea.Bool(true),
 		},
@@ -71,6 +188,9 @@ func (s *StreamCardClient) CreateAndDeliverCard(accessToken string, req *CreateA
 	if req.OpenSpaceID != "" {
 		createReq.SetOpenSpaceId(req.OpenSpaceID)
 	}
+	if req.CallbackRouteKey != "" {
+		createReq.SetCallbackRouteKey(req.CallbackRouteKey)
+	}
 
 	// Handle different conversation types with appropriate delivery models
 	switch req.ConversationType {
@@ -83,10 +203,13 @@ func (s *StreamCardClient) CreateAndDeliverCard(accessToken string, req *CreateA
 		}
 	case "1": // Private chat with robot
 		// For private chat, use ImRobotOpenDeliverModel with SpaceType
-		createReq.SetImRobotOpenDeliverModel(
-			&dingtalk.CreateAndDeliverRequestImRobotOpenDeliverModel{
-				SpaceType: tea.String("IM_GROUP"),
-			})
+		deliverModel := &dingtalk.CreateAndDeliverRequestImRobotOpenDeliverModel{
+			SpaceType: tea.String(SpaceTypeIMRobot),
+		}
+		if req.RobotCode != "" {
+			deliverModel.SetRobotCode(req.RobotCode)
+		}
+		createReq.SetImRobotOpenDeliverModel(deliverModel)
 	default:
 		// Fallback to group model if conversation type is unknown
 		if req.RobotCode != "" {
@@ -97,8 +220,46 @@ func (s *StreamCardClient) CreateAndDeliverCard(accessToken string, req *CreateA
 		}
 	}
 
-	_, err := s.client.CreateAndDeliverWithOptions(createReq, headers, &util.RuntimeOptions{})
-	return err
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/instances/createAndDeliver", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.CreateAndDeliverHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.CreateAndDeliverWithOptions(createReq, headers, runtime)
+		return err
+	})
+}
+
+// ReplyCard 在机器人收到消息的会话中回复互动卡片
+func (s *StreamCardClient) ReplyCard(accessToken string, msg *message.ReceiveMsg, cardTemplateID string, cardData CardData) (string, error) {
+	return s.ReplyCardWithContext(context.Background(), accessToken, msg, cardTemplateID, cardData)
+}
+
+// ReplyCardWithContext 在机器人收到消息的会话中回复互动卡片，返回生成的 OutTrackID，用于后续流式更新
+// 群聊投放到 msg.ConversationID 对应的群，单聊投放到与发送人的机器人单聊
+func (s *StreamCardClient) ReplyCardWithContext(ctx context.Context, accessToken string, msg *message.ReceiveMsg, cardTemplateID string, cardData CardData) (string, error) {
+	req := &CreateAndDeliverCardRequest{
+		CardTemplateID:   cardTemplateID,
+		OutTrackID:       uuid.New().String(),
+		ConversationID:   msg.ConversationID,
+		SenderStaffID:    msg.SenderStaffId,
+		RobotCode:        msg.RobotCode,
+		OpenSpaceID:      OpenSpaceID(msg),
+		ConversationType: msg.ConversationType,
+		CardData:         cardData,
+	}
+	if err := s.CreateAndDeliverCardWithContext(ctx, accessToken, req); err != nil {
+		return "", err
+	}
+	return req.OutTrackID, nil
+}
+
+// OpenSpaceID 生成消息所在会话的卡片投放空间 ID
+// 群聊为 dtv1.card//IM_GROUP.{openConversationId}，单聊为 dtv1.card//IM_ROBOT.{userId}
+func OpenSpaceID(msg *message.ReceiveMsg) string {
+	if msg.ConversationType == message.ConversationTypeSingle {
+		return RobotSpaceID(msg.SenderStaffId)
+	}
+	return GroupSpaceID(msg.ConversationID)
 }
 
 // StreamingUpdateRequest 流式更新请求
@@ -108,14 +269,16 @@ type StreamingUpdateRequest struct {
 	Content    string
 	IsFull     bool
 	IsFinalize bool
+	IsError    bool // 标记卡片生成失败，通常与 IsFinalize 同时使用
 }
 
 // StreamingUpdate 流式更新卡片内容
 func (s *StreamCardClient) StreamingUpdate(accessToken string, req *StreamingUpdateRequest) error {
-	headers := &dingtalk.StreamingUpdateHeaders{
-		XAcsDingtalkAccessToken: tea.String(accessToken),
-	}
+	return s.StreamingUpdateWithContext(context.Background(), accessToken, req)
+}
 
+// StreamingUpdateWithContext 流式更新卡片内容，ctx 取消时立即返回
+func (s *StreamCardClient) StreamingUpdateWithContext(ctx context.Context, accessToken string, req *StreamingUpdateRequest) error {
 	updateReq := &dingtalk.StreamingUpdateRequest{
 		OutTrackId: tea.String(req.OutTrackID),
 		Guid:       tea.String(uuid.New().String()),
@@ -123,17 +286,61 @@ func (s *StreamCardClient) StreamingUpdate(accessToken string, req *StreamingUpd
 		Content:    tea.String(req.Content),
 		IsFull:     tea.Bool(req.IsFull),
 		IsFinalize: tea.Bool(req.IsFinalize),
-		IsError:    tea.Bool(false),
+		IsError:    tea.Bool(req.IsError),
 	}
 
-	_, err := s.client.StreamingUpdateWithOptions(updateReq, headers, &util.RuntimeOptions{})
-	return err
+	return s.callWithToken(ctx, accessToken, "/v1.0/card/streaming", func(accessToken string, runtime *util.RuntimeOptions) error {
+		headers := &dingtalk.StreamingUpdateHeaders{
+			XAcsDingtalkAccessToken: tea.String(accessToken),
+		}
+		_, err := s.client.StreamingUpdateWithOptions(updateReq, headers, runtime)
+		return err
+	})
+}
+
+// FinalizeWithError 以错误状态结束流式卡片，content 替换卡片中 key 对应的内容
+func (s *StreamCardClient) FinalizeWithError(accessToken, outTrackID, key, content string) error {
+	return s.FinalizeWithErrorWithContext(context.Background(), accessToken, outTrackID, key, content)
+}
+
+// FinalizeWithErrorWithContext 以错误状态结束流式卡片，ctx 取消时立即返回
+func (s *StreamCardClient) FinalizeWithErrorWithContext(ctx context.Context, accessToken, outTrackID, key, content string) error {
+	if key == "" {
+		key = DefaultStreamKey
+	}
+	return s.StreamingUpdateWithContext(ctx, accessToken, &StreamingUpdateRequest{
+		OutTrackID: outTrackID,
+		Key:        key,
+		Content:    content,
+		IsFull:     true,
+		IsFinalize: true,
+		IsError:    true,
+	})
+}
+
+var (
+	defaultCardClient     *StreamCardClient
+	defaultCardClientErr  error
+	defaultCardClientOnce sync.Once
+)
+
+// getDefaultCardClient 返回包级共享的流式卡片客户端，避免每次更新都创建新客户端
+func getDefaultCardClient() (*StreamCardClient, error) {
+	defaultCardClientOnce.Do(func() {
+		defaultCardClient, defaultCardClientErr = NewStreamCardClient()
+	})
+	return defaultCardClient, defaultCardClientErr
 }
 
 // UpdateAIStreamCard 更新AI流式卡片 (简化版本,不依赖卡片模板)
-// 这个方法需要与 client 包集成，这里提供一个独立实现
+// 使用包级共享客户端；需要自动管理 Token 时使用 NewStreamCardClientWithTokenProvider
 func UpdateAIStreamCard(accessToken, trackID, content string, isFinalize bool) error {
-	cardClient, err := NewStreamCardClient()
+	return UpdateAIStreamCardWithContext(context.Background(), accessToken, trackID, content, isFinalize)
+}
+
+// UpdateAIStreamCardWithContext 更新AI流式卡片，ctx 取消时立即返回
+func UpdateAIStreamCardWithContext(ctx context.Context, accessToken, trackID, content string, isFinalize bool) error {
+	cardClient, err := getDefaultCardClient()
 	if err != nil {
 		return fmt.Errorf("failed to create stream card client: %w", err)
 	}
@@ -146,5 +353,5 @@ func UpdateAIStreamCard(accessToken, trackID, content string, isFinalize bool) e
 		IsFinalize: isFinalize,
 	}
 
-	return cardClient.StreamingUpdate(accessToken, req)
+	return cardClient.StreamingUpdateWithContext(ctx, accessToken, req)
 }
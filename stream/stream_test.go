@@ -0,0 +1,426 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/card"
+
+	"github.com/difyz9/dingtalk-sdk.git/message"
+)
+
+// recorder 记录发送的流式更新
+type recorder struct {
+	mutex    sync.Mutex
+	requests []StreamingUpdateRequest
+	err      error
+}
+
+func (r *recorder) update(ctx context.Context, req *StreamingUpdateRequest) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, *req)
+	return r.err
+}
+
+func (r *recorder) all() []StreamingUpdateRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]StreamingUpdateRequest(nil), r.requests...)
+}
+
+func TestCardStreamWriter(t *testing.T) {
+	rec := &recorder{}
+	w := newCardStreamWriter(context.Background(), rec.update, "track1", &CardStreamOptions{FlushInterval: time.Hour, FlushSize: -1})
+	for _, token := range []string{"你好", "，", "世界"} {
+		if _, err := w.WriteString(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rec.all()) != 0 {
+		t.Fatal("Expected tokens to be buffered")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// 没有新内容时不发送
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("!")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Expected repeated Close to succeed, got %v", err)
+	}
+	if _, err := w.WriteString("x"); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Expected ErrWriterClosed, got %v", err)
+	}
+
+	requests := rec.all()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 updates, got %+v", requests)
+	}
+	first, last := requests[0], requests[1]
+	if first.Content != "你好，世界" || !first.IsFull || first.IsFinalize || first.Key != DefaultStreamKey || first.OutTrackID != "track1" {
+		t.Errorf("Unexpected first update %+v", first)
+	}
+	if last.Content != "你好，世界!" || !last.IsFinalize {
+		t.Errorf("Unexpected final update %+v", last)
+	}
+}
+
+func TestCardStreamWriterAppend(t *testing.T) {
+	rec := &recorder{}
+	w := newCardStreamWriter(context.Background(), rec.update, "track1", &CardStreamOptions{Key: "answer", FlushInterval: time.Hour, FlushSize: 4, Append: true})
+	w.WriteString("abcd")
+	// 达到大小阈值后由后台 goroutine 发送
+	deadline := time.Now().Add(time.Second)
+	for len(rec.all()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 5)
+	}
+	w.WriteString("ef")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	for _, req := range rec.all() {
+		if req.IsFull || req.Key != "answer" {
+			t.Errorf("Unexpected append update %+v", req)
+		}
+		contents = append(contents, req.Content)
+	}
+	if strings.Join(contents, "|") != "abcd|ef" {
+		t.Errorf("Unexpected append contents %q", contents)
+	}
+}
+
+func TestCardStreamWriterSplitRune(t *testing.T) {
+	rec := &recorder{}
+	w := newCardStreamWriter(context.Background(), rec.update, "track1", &CardStreamOptions{FlushInterval: time.Hour, FlushSize: -1, Append: true})
+	data := []byte("你好")
+	w.Write(data[:4])
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[4:])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	for _, req := range rec.all() {
+		if !utf8.ValidString(req.Content) {
+			t.Errorf("Expected complete runes in update, got %q", req.Content)
+		}
+		contents = append(contents, req.Content)
+	}
+	if strings.Join(contents, "|") != "你|好" {
+		t.Errorf("Unexpected append contents %q", contents)
+	}
+}
+
+func TestCardStreamWriterCancel(t *testing.T) {
+	rec := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newCardStreamWriter(ctx, rec.update, "track1", &CardStreamOptions{FlushInterval: time.Hour})
+	w.WriteString("partial")
+	cancel()
+	if err := w.Close(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Close, got %v", err)
+	}
+
+	requests := rec.all()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 update, got %+v", requests)
+	}
+	if req := requests[0]; !req.IsFinalize || !req.IsError || req.Content != "partial\n\n"+DefaultStreamErrorMessage {
+		t.Errorf("Expected error finalize on cancel, got %+v", req)
+	}
+}
+
+func TestCardStreamWriterCloseWithError(t *testing.T) {
+	rec := &recorder{}
+	w := newCardStreamWriter(context.Background(), rec.update, "track1", &CardStreamOptions{
+		FlushInterval: time.Hour,
+		Append:        true,
+		ErrorMessage:  func(err error) string { return "失败: " + err.Error() },
+	})
+	w.WriteString("abc")
+	w.Flush()
+	w.WriteString("def")
+	if err := w.CloseWithError(errors.New("model timeout")); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := rec.all()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 updates, got %+v", requests)
+	}
+	// 错误结束时发送全量内容
+	if req := requests[1]; !req.IsFull || !req.IsError || !req.IsFinalize || req.Content != "abcdef\n\n失败: model timeout" {
+		t.Errorf("Unexpected error update %+v", req)
+	}
+}
+
+func TestCardStreamWriterError(t *testing.T) {
+	rec := &recorder{err: errors.New("qps limit")}
+	w := newCardStreamWriter(context.Background(), rec.update, "track1", &CardStreamOptions{FlushInterval: time.Hour})
+	w.WriteString("a")
+	if err := w.Flush(); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	if _, err := w.WriteString("b"); err == nil {
+		t.Error("Expected write to return previous update error")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Expected close to return update error")
+	}
+	// 更新失败后 Close 仍尝试以错误状态结束卡片
+	if requests := rec.all(); len(requests) != 2 || !requests[1].IsError {
+		t.Errorf("Expected error finalize after failed update, got %+v", requests)
+	}
+}
+
+func TestOpenSpaceID(t *testing.T) {
+	group := &message.ReceiveMsg{ConversationType: message.ConversationTypeGroup, ConversationID: "cid1"}
+	if got := OpenSpaceID(group); got != "dtv1.card//IM_GROUP.cid1" {
+		t.Errorf("Unexpected group space id %q", got)
+	}
+	single := &message.ReceiveMsg{ConversationType: message.ConversationTypeSingle, SenderStaffId: "user1"}
+	if got := OpenSpaceID(single); got != "dtv1.card//IM_ROBOT.user1" {
+		t.Errorf("Unexpected single space id %q", got)
+	}
+}
+
+// fakeTokenProvider 每次失效后返回新的 Token
+type fakeTokenProvider struct {
+	version     int
+	invalidated int
+}
+
+func (p *fakeTokenProvider) GetAccessTokenWithContext(ctx context.Context) (string, error) {
+	return "token" + string(rune('0'+p.version)), nil
+}
+
+func (p *fakeTokenProvider) InvalidateAccessTokenIfCurrent(accessToken string) {
+	if accessToken != "token"+string(rune('0'+p.version)) {
+		return
+	}
+	p.invalidated++
+	p.version++
+}
+
+func TestCallWithToken(t *testing.T) {
+	ctx := context.Background()
+	plain, err := NewStreamCardClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	noop := func(string, *util.RuntimeOptions) error { return nil }
+	if err = plain.callWithToken(ctx, "", "/test", noop); !errors.Is(err, ErrNoAccessToken) {
+		t.Errorf("Expected ErrNoAccessToken, got %v", err)
+	}
+
+	provider := &fakeTokenProvider{}
+	cardClient, err := NewStreamCardClientWithTokenProvider(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个 provider 使用独立的客户端，不能修改包级共享客户端
+	other, err := NewStreamCardClientWithTokenProvider(&fakeTokenProvider{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := getDefaultCardClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cardClient == other || cardClient == shared || shared.tokenProvider != nil {
+		t.Error("Expected a separate client for each token provider")
+	}
+	var tokens []string
+	err = cardClient.callWithToken(ctx, "", "/test", func(accessToken string, runtime *util.RuntimeOptions) error {
+		tokens = append(tokens, accessToken)
+		if accessToken == "token0" {
+			return &tea.SDKError{StatusCode: tea.Int(401), Code: tea.String("InvalidAuthentication")}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tokens, ",") != "token0,token1" || provider.invalidated != 1 {
+		t.Errorf("Expected refresh and retry once, got tokens %v, invalidated %d", tokens, provider.invalidated)
+	}
+
+	// 显式传入的 accessToken 优先
+	tokens = nil
+	cardClient.callWithToken(ctx, "explicit", "/test", func(accessToken string, runtime *util.RuntimeOptions) error {
+		tokens = append(tokens, accessToken)
+		return nil
+	})
+	if len(tokens) != 1 || tokens[0] != "explicit" {
+		t.Errorf("Expected explicit token, got %v", tokens)
+	}
+}
+
+func TestCardData(t *testing.T) {
+	data := CardData{"title": "标题"}
+	if err := data.SetJSON("items", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	params := data.paramMap()
+	if tea.StringValue(params["title"]) != "标题" || tea.StringValue(params["items"]) != `["a","b"]` {
+		t.Errorf("Unexpected param map %v", data)
+	}
+
+	if PrivateData(nil).toSDK() != nil {
+		t.Error("Expected nil private data")
+	}
+	private := PrivateData{"user1": {"status": "已投票"}}.toSDK()
+	if tea.StringValue(private["user1"].CardParamMap["status"]) != "已投票" {
+		t.Errorf("Unexpected private data %+v", private)
+	}
+}
+
+func TestBuildDeliverRequest(t *testing.T) {
+	req := buildDeliverRequest(&DeliverCardRequest{
+		OutTrackID:  "track1",
+		OpenSpaceID: GroupSpaceID("cid1") + ";" + SpaceTypeIMRobot + ".user1",
+		RobotCode:   "robot1",
+		Recipients:  []string{"user2"},
+	})
+	if req.ImGroupOpenDeliverModel == nil || tea.StringValue(req.ImGroupOpenDeliverModel.RobotCode) != "robot1" ||
+		len(req.ImGroupOpenDeliverModel.Recipients) != 1 {
+		t.Errorf("Unexpected group deliver model %+v", req.ImGroupOpenDeliverModel)
+	}
+	if req.ImRobotOpenDeliverModel == nil || tea.StringValue(req.ImRobotOpenDeliverModel.SpaceType) != SpaceTypeIMRobot {
+		t.Errorf("Unexpected robot deliver model %+v", req.ImRobotOpenDeliverModel)
+	}
+	if req.ImSingleOpenDeliverModel != nil {
+		t.Error("Expected no single deliver model")
+	}
+}
+
+func TestCardCallbackRouter(t *testing.T) {
+	router := NewCardCallbackRouter()
+	router.HandleAction("vote", func(ctx context.Context, action *CardAction) (*CardUpdate, error) {
+		form := struct {
+			Option string `json:"option"`
+			Count  int    `json:"count"`
+		}{}
+		if err := action.Bind(&form); err != nil {
+			return nil, err
+		}
+		return &CardUpdate{
+			CardData:               CardData{"result": form.Option},
+			PrivateData:            CardData{"voted": "true"},
+			UpdateCardDataByKey:    true,
+			UpdatePrivateDataByKey: true,
+		}, nil
+	})
+	router.HandleCard("closed", func(ctx context.Context, action *CardAction) (*CardUpdate, error) {
+		return &CardUpdate{CardData: CardData{"status": "closed"}}, nil
+	})
+	content := `{"cardPrivateData":{"actionIds":["vote"],"params":{"option":"A","count":2}}}`
+
+	// Stream 模式
+	handler := router.CardCallbackHandler()
+	resp, err := handler(context.Background(), &card.CardRequest{OutTrackId: "track1", UserId: "user1", Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.CardData.CardParamMap["result"] != "A" || resp.UserPrivateData.CardParamMap["voted"] != "true" ||
+		!resp.CardUpdateOptions.UpdateCardDataByKey {
+		t.Errorf("Unexpected card response %+v", resp)
+	}
+	// OutTrackID 优先于 actionId
+	resp, _ = handler(context.Background(), &card.CardRequest{OutTrackId: "closed", Content: content})
+	if resp.CardData.CardParamMap["status"] != "closed" {
+		t.Errorf("Expected card handler, got %+v", resp)
+	}
+	// 未匹配时不更新卡片
+	resp, err = handler(context.Background(), &card.CardRequest{OutTrackId: "track1", Content: `{"cardPrivateData":{"actionIds":["other"]}}`})
+	if err != nil || resp.CardData != nil || resp.CardUpdateOptions != nil {
+		t.Errorf("Expected empty response, got %+v, %v", resp, err)
+	}
+
+	// HTTP 模式，未设置校验时拒绝所有请求
+	body, _ := json.Marshal(map[string]string{"outTrackId": "track1", "userId": "user1", "content": content})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/card?token=secret", strings.NewReader(string(body))))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without verifier, got %d", rec.Code)
+	}
+	router.SetVerifier(QueryTokenVerifier("token", "secret"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/card?token=wrong", strings.NewReader(string(body))))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for wrong token, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/card?token=secret", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	httpResp := &card.CardResponse{}
+	if err = json.Unmarshal(rec.Body.Bytes(), httpResp); err != nil || httpResp.CardData.CardParamMap["result"] != "A" {
+		t.Errorf("Unexpected http response %s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/card?token=secret", strings.NewReader(`{"content":"x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid content, got %d", rec.Code)
+	}
+
+	// 处理函数的错误只写入日志
+	var logs bytes.Buffer
+	router.ErrorLog = log.New(&logs, "", 0)
+	router.HandleCard("broken", func(ctx context.Context, action *CardAction) (*CardUpdate, error) {
+		return nil, errors.New("db password wrong")
+	})
+	body, _ = json.Marshal(map[string]string{"outTrackId": "broken", "content": content})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/card?token=secret", strings.NewReader(string(body))))
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("Expected generic 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "db password wrong") {
+		t.Errorf("Expected error to be logged, got %q", logs.String())
+	}
+
+	// HandleAction 传入 nil 时取消注册，交给兜底处理函数
+	router.HandleAction("vote", nil)
+	router.HandleDefault(func(ctx context.Context, action *CardAction) (*CardUpdate, error) {
+		return &CardUpdate{CardData: CardData{"result": "default"}}, nil
+	})
+	resp, err = handler(context.Background(), &card.CardRequest{OutTrackId: "track1", Content: content})
+	if err != nil || resp.CardData.CardParamMap["result"] != "default" {
+		t.Errorf("Expected default handler, got %+v, %v", resp, err)
+	}
+}
+
+func TestCardActionParam(t *testing.T) {
+	action, err := parseCardAction(&cardCallbackRequest{Content: `{"cardPrivateData":{"actionIds":["a","b"],"params":{"name":"张三","age":18}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action.ActionID() != "a" || action.Param("name") != "张三" || action.Param("age") != "" || action.Param("missing") != "" {
+		t.Errorf("Unexpected action %+v", action)
+	}
+}
@@ -0,0 +1,331 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 流式写入的默认参数
+const (
+	DefaultStreamKey           = "content"
+	DefaultStreamFlushInterval = time.Millisecond * 300
+	DefaultStreamFlushSize     = 512
+	// DefaultStreamErrorMessage 以错误状态结束时追加到卡片内容后的提示
+	DefaultStreamErrorMessage = "生成失败，请稍后重试"
+	// finalizeTimeout ctx 取消后发送最终更新使用的超时
+	finalizeTimeout = time.Second * 10
+)
+
+// ErrWriterClosed 写入已关闭的 CardStreamWriter
+var ErrWriterClosed = errors.New("stream: card stream writer is closed")
+
+// CardStreamOptions 流式写入选项
+type CardStreamOptions struct {
+	Key           string        // 卡片模板中的流式变量名，默认 DefaultStreamKey
+	FlushInterval time.Duration // 定时刷新间隔，默认 DefaultStreamFlushInterval
+	FlushSize     int           // 未刷新内容达到该字节数时立即刷新，默认 DefaultStreamFlushSize，小于 0 表示仅按间隔刷新；只发送到最后一个完整的 UTF-8 字符
+	Append        bool          // 为 true 时每次只发送新增内容（IsFull=false），默认发送全量内容（IsFull=true），乱序或丢失时不影响最终结果
+	// ErrorMessage 根据错误生成提示，以错误状态结束时追加到已写入内容之后，默认返回 DefaultStreamErrorMessage
+	ErrorMessage func(err error) string
+}
+
+// updateFunc 发送一次流式更新
+type updateFunc func(ctx context.Context, req *StreamingUpdateRequest) error
+
+// CardStreamWriter 绑定到卡片 OutTrackID 与变量的流式写入器，实现 io.Writer
+// 写入的内容先缓存，按间隔或大小阈值合并为一次更新，所有更新由同一个 goroutine 顺序发送；
+// Close 或 ctx 取消时保证发送一次 IsFinalize 更新；ctx 取消、CloseWithError 或之前的更新失败时以 IsError 结束
+type CardStreamWriter struct {
+	update       updateFunc
+	outTrackID   string
+	key          string
+	append       bool
+	flushSize    int
+	errorMessage func(err error) string
+
+	mutex    sync.Mutex
+	content  strings.Builder // 已写入的全部内容
+	sent     int             // 已发送的内容长度
+	err      error           // 首个更新错误，之后的写入直接返回
+	canceled error           // 因 ctx 取消以错误状态结束时为 ctx.Err()
+	closed   bool
+
+	ctx     context.Context
+	signal  chan struct{}
+	flushes chan chan error
+	closing chan closeRequest
+	done    chan struct{}
+}
+
+// closeRequest 结束请求，cause 不为 nil 时以错误状态结束
+type closeRequest struct {
+	cause  error
+	result chan error
+}
+
+// NewCardStreamWriter 创建流式写入器，opts 为 nil 时使用默认选项
+// 使用完毕必须调用 Close 或 CloseWithError；ctx 取消时视为放弃，自动以错误状态结束卡片并停止写入
+func (s *StreamCardClient) NewCardStreamWriter(ctx context.Context, accessToken, outTrackID string, opts *CardStreamOptions) *CardStreamWriter {
+	return newCardStreamWriter(ctx, func(ctx context.Context, req *StreamingUpdateRequest) error {
+		return s.StreamingUpdateWithContext(ctx, accessToken, req)
+	}, outTrackID, opts)
+}
+
+func newCardStreamWriter(ctx context.Context, update updateFunc, outTrackID string, opts *CardStreamOptions) *CardStreamWriter {
+	if opts == nil {
+		opts = &CardStreamOptions{}
+	}
+	w := &CardStreamWriter{
+		update:       update,
+		outTrackID:   outTrackID,
+		key:          opts.Key,
+		append:       opts.Append,
+		flushSize:    opts.FlushSize,
+		errorMessage: opts.ErrorMessage,
+		ctx:          ctx,
+		signal:       make(chan struct{}, 1),
+		flushes:      make(chan chan error),
+		closing:      make(chan closeRequest),
+		done:         make(chan struct{}),
+	}
+	if w.key == "" {
+		w.key = DefaultStreamKey
+	}
+	if w.flushSize == 0 {
+		w.flushSize = DefaultStreamFlushSize
+	}
+	if w.errorMessage == nil {
+		w.errorMessage = func(error) string { return DefaultStreamErrorMessage }
+	}
+	interval := opts.FlushInterval
+	if interval <= 0 {
+		interval = DefaultStreamFlushInterval
+	}
+	go w.loop(interval)
+	return w
+}
+
+// Write 追加内容，返回之前发生的更新错误
+func (w *CardStreamWriter) Write(p []byte) (int, error) {
+	return w.WriteString(string(p))
+}
+
+// WriteString 追加内容
+func (w *CardStreamWriter) WriteString(s string) (int, error) {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		err := w.err
+		w.mutex.Unlock()
+		return 0, err
+	}
+	w.content.WriteString(s)
+	full := w.flushSize > 0 && w.content.Len()-w.sent >= w.flushSize
+	w.mutex.Unlock()
+
+	if full {
+		select {
+		case w.signal <- struct{}{}:
+		default:
+		}
+	}
+	return len(s), nil
+}
+
+// Content 返回已写入的全部内容
+func (w *CardStreamWriter) Content() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.content.String()
+}
+
+// Flush 立即发送未刷新的内容
+func (w *CardStreamWriter) Flush() error {
+	result := make(chan error, 1)
+	select {
+	case w.flushes <- result:
+		return <-result
+	case <-w.done:
+		return w.closeErr()
+	}
+}
+
+// Close 发送剩余内容并结束流式更新（IsFinalize），重复调用返回相同结果
+// 之前的更新失败时改为以错误状态结束，并返回该错误；ctx 取消导致以错误状态结束时返回 ctx.Err()
+func (w *CardStreamWriter) Close() error {
+	return w.close(nil)
+}
+
+// CloseWithError 以错误状态结束流式更新（IsFinalize 与 IsError），已写入的内容之后追加 ErrorMessage 生成的提示
+// cause 为 nil 时等同于 Close；已结束时不再发送
+func (w *CardStreamWriter) CloseWithError(cause error) error {
+	return w.close(cause)
+}
+
+func (w *CardStreamWriter) close(cause error) error {
+	req := closeRequest{cause: cause, result: make(chan error, 1)}
+	select {
+	case w.closing <- req:
+		return <-req.result
+	case <-w.done:
+		return w.closeErr()
+	}
+}
+
+// loop 唯一发送更新的 goroutine，保证更新顺序
+func (w *CardStreamWriter) loop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush(w.ctx, false)
+		case <-w.signal:
+			w.flush(w.ctx, false)
+		case result := <-w.flushes:
+			result <- w.flush(w.ctx, false)
+		case req := <-w.closing:
+			// ctx 已取消时同样视为放弃，并使用独立的超时发送
+			cause := req.cause
+			if cause == nil && w.ctx.Err() != nil {
+				cause = w.cancel()
+			}
+			ctx, cancel := w.finalizeContext()
+			w.finalize(ctx, cause)
+			cancel()
+			req.result <- w.closeErr()
+			return
+		case <-w.ctx.Done():
+			// 调用方未 Close 就放弃了写入，以错误状态结束，避免卡片一直处于生成中
+			ctx, cancel := w.finalizeContext()
+			w.finalize(ctx, w.cancel())
+			cancel()
+			return
+		}
+	}
+}
+
+// cancel 记录因 ctx 取消而结束，之后 Close 返回 ctx.Err()
+func (w *CardStreamWriter) cancel() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.canceled = w.ctx.Err()
+	return w.canceled
+}
+
+// finalizeContext 发送最终更新使用的 ctx，w.ctx 取消后改用独立的超时
+func (w *CardStreamWriter) finalizeContext() (context.Context, context.CancelFunc) {
+	if w.ctx.Err() != nil {
+		return context.WithTimeout(context.Background(), finalizeTimeout)
+	}
+	return context.WithCancel(w.ctx)
+}
+
+// flush 发送未刷新的内容，没有新内容时跳过；finalize 为 true 时总会发送
+func (w *CardStreamWriter) flush(ctx context.Context, finalize bool) error {
+	w.mutex.Lock()
+	if w.err != nil {
+		err := w.err
+		w.mutex.Unlock()
+		return err
+	}
+	content := w.content.String()
+	sent := w.sent
+	w.mutex.Unlock()
+
+	if !finalize {
+		// 多字节字符可能被拆分到两次 Write，未写完的字符留到下次发送
+		content = content[:completeLength(content)]
+	}
+	if len(content) == sent && !finalize {
+		return nil
+	}
+	req := &StreamingUpdateRequest{
+		OutTrackID: w.outTrackID,
+		Key:        w.key,
+		Content:    content,
+		IsFull:     !w.append,
+		IsFinalize: finalize,
+	}
+	if w.append {
+		req.Content = content[sent:]
+	}
+	err := w.update(ctx, req)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err != nil {
+		w.err = err
+		return err
+	}
+	w.sent = len(content)
+	return nil
+}
+
+// finalize 停止写入并发送最终更新，cause 不为 nil 或之前的更新失败时以错误状态结束
+func (w *CardStreamWriter) finalize(ctx context.Context, cause error) error {
+	w.mutex.Lock()
+	w.closed = true
+	prevErr := w.err
+	content := w.content.String()
+	w.mutex.Unlock()
+
+	if cause == nil {
+		cause = prevErr
+	}
+	if cause == nil {
+		return w.flush(ctx, true)
+	}
+
+	// 错误提示接在已写入内容之后，始终发送全量内容，追加模式下缺失的片段也能补齐
+	if content != "" {
+		content += "\n\n"
+	}
+	err := w.update(ctx, &StreamingUpdateRequest{
+		OutTrackID: w.outTrackID,
+		Key:        w.key,
+		Content:    content + w.errorMessage(cause),
+		IsFull:     true,
+		IsFinalize: true,
+		IsError:    true,
+	})
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// closeErr 关闭后的结果
+func (w *CardStreamWriter) closeErr() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	return w.canceled
+}
+
+// completeLength 返回 s 中以完整 UTF-8 字符结尾的最长前缀的长度
+func completeLength(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if utf8.FullRuneInString(s[i:]) {
+				return len(s)
+			}
+			return i
+		}
+	}
+	return len(s)
+}